@@ -3,8 +3,10 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"strings"
 	"testing"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -79,7 +81,7 @@ func TestAzureAccountListArgs(t *testing.T) {
 }
 
 func TestNewApp(t *testing.T) {
-	app := NewApp()
+	app := NewApp(NewSampleProvider())
 
 	// Test that app is properly initialized
 	if app.state != StateLoading {
@@ -159,7 +161,7 @@ func TestParseSubscriptions_InvalidJSON(t *testing.T) {
 
 func TestIsAzureCLIAvailable(t *testing.T) {
 	// This test depends on the system state, but we can test the function exists
-	available := isAzureCLIAvailable()
+	available := isAzureCLIAvailable(AzureCommand)
 	// Result could be true or false depending on system, just verify no panic
 	_ = available
 }
@@ -189,7 +191,7 @@ func TestFindDefaultSubscription(t *testing.T) {
 }
 
 func TestApp_Update_WindowSize(t *testing.T) {
-	app := NewApp()
+	app := NewApp(NewSampleProvider())
 
 	msg := tea.WindowSizeMsg{
 		Width:  800,
@@ -210,7 +212,7 @@ func TestApp_Update_WindowSize(t *testing.T) {
 }
 
 func TestApp_Update_KeyMsg_Quit(t *testing.T) {
-	app := NewApp()
+	app := NewApp(NewSampleProvider())
 
 	msg := tea.KeyMsg{
 		Type:  tea.KeyRunes,
@@ -224,7 +226,7 @@ func TestApp_Update_KeyMsg_Quit(t *testing.T) {
 }
 
 func TestApp_View_States(t *testing.T) {
-	app := NewApp()
+	app := NewApp(NewSampleProvider())
 
 	// Test loading view
 	app.state = StateLoading
@@ -251,20 +253,20 @@ func TestApp_View_States(t *testing.T) {
 
 func TestNewResultPage(t *testing.T) {
 	// Test with changed = true
-	rp := NewResultPage(true)
+	rp := NewResultPage(true, ResultTimeout, DefaultTheme())
 	if !rp.changed {
 		t.Error("Expected changed to be true")
 	}
 
 	// Test with changed = false
-	rp = NewResultPage(false)
+	rp = NewResultPage(false, ResultTimeout, DefaultTheme())
 	if rp.changed {
 		t.Error("Expected changed to be false")
 	}
 }
 
 func TestResultPage_Init(t *testing.T) {
-	rp := NewResultPage(true)
+	rp := NewResultPage(true, ResultTimeout, DefaultTheme())
 	cmd := rp.Init()
 
 	// Verify that a command is returned (timer initialization)
@@ -279,7 +281,7 @@ func TestResultPage_View(t *testing.T) {
 	height = 20
 
 	// Test changed result
-	rp := NewResultPage(true)
+	rp := NewResultPage(true, ResultTimeout, DefaultTheme())
 	view := rp.View()
 
 	if view == "" {
@@ -287,7 +289,7 @@ func TestResultPage_View(t *testing.T) {
 	}
 
 	// Test no change result
-	rp = NewResultPage(false)
+	rp = NewResultPage(false, ResultTimeout, DefaultTheme())
 	view = rp.View()
 
 	if view == "" {
@@ -317,7 +319,7 @@ func BenchmarkParseSubscriptions(b *testing.B) {
 
 // Error handling tests
 func TestAppError_Classification(t *testing.T) {
-	app := NewApp()
+	app := NewApp(NewSampleProvider())
 
 	tests := []struct {
 		name         string
@@ -327,25 +329,25 @@ func TestAppError_Classification(t *testing.T) {
 	}{
 		{
 			name:         "Network error",
-			err:          errors.New("network connection failed"),
+			err:          fmt.Errorf("failed to fetch subscription data: %w", ErrNetworkTimeout),
 			expectedType: ErrorTypeNetwork,
 			retryable:    true,
 		},
 		{
 			name:         "Authentication error",
-			err:          errors.New("authentication failed"),
+			err:          ErrUnauthorized,
 			expectedType: ErrorTypeAuth,
 			retryable:    false,
 		},
 		{
 			name:         "Permission error",
-			err:          errors.New("permission denied"),
+			err:          &fs.PathError{Op: "open", Path: "azureProfile.json", Err: fs.ErrPermission},
 			expectedType: ErrorTypePermission,
 			retryable:    false,
 		},
 		{
 			name:         "CLI not found",
-			err:          errors.New("az command not found"),
+			err:          ErrAzureCLINotFound,
 			expectedType: ErrorTypeConfig,
 			retryable:    false,
 		},
@@ -355,6 +357,19 @@ func TestAppError_Classification(t *testing.T) {
 			expectedType: ErrorTypeUnknown,
 			retryable:    true,
 		},
+		{
+			// Mentions of az or login in untyped errors are not classified
+			name:         "Untyped error",
+			err:          errors.New("failed to parse az output after login"),
+			expectedType: ErrorTypeUnknown,
+			retryable:    true,
+		},
+		{
+			name:         "Azure CLI error",
+			err:          parseAzureCLIError(errors.New("exit status 1"), []byte("ERROR: Please run 'az login' to setup account.\n")),
+			expectedType: ErrorTypeAuth,
+			retryable:    false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -381,10 +396,10 @@ func TestAppError_Classification(t *testing.T) {
 }
 
 func TestApp_ShouldRetry(t *testing.T) {
-	app := NewApp()
+	app := NewApp(NewSampleProvider())
 
 	// Test retryable error within limit
-	networkErr := errors.New("network timeout")
+	networkErr := ErrNetworkTimeout
 	if !app.shouldRetry(networkErr) {
 		t.Error("Should retry network error when under retry limit")
 	}
@@ -397,14 +412,14 @@ func TestApp_ShouldRetry(t *testing.T) {
 
 	// Test non-retryable error
 	app.retryCount = 0
-	authErr := errors.New("authentication failed")
+	authErr := ErrUnauthorized
 	if app.shouldRetry(authErr) {
 		t.Error("Should not retry authentication error")
 	}
 }
 
 func TestApp_ErrorHandling_Integration(t *testing.T) {
-	app := NewApp()
+	app := NewApp(NewSampleProvider())
 
 	// Test handling subscription load error with retry
 	msg := SubscriptionsLoadedMsg{
@@ -437,7 +452,7 @@ func TestApp_ErrorHandling_Integration(t *testing.T) {
 }
 
 func TestApp_RetryView(t *testing.T) {
-	app := NewApp()
+	app := NewApp(NewSampleProvider())
 	app.state = StateRetrying
 	app.retryCount = 2
 	app.maxRetries = MaxRetries
@@ -454,3 +469,17 @@ func TestApp_RetryView(t *testing.T) {
 		t.Errorf("Retry view should contain retry count information, got: %s", view)
 	}
 }
+
+func TestApp_RetryDelay(t *testing.T) {
+	config := DefaultConfig()
+	config.BaseDelay = 100 * time.Millisecond
+	config.MaxDelay = 300 * time.Millisecond
+	app := newApp(NewSampleProvider(), config, DefaultTheme())
+
+	for retries, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond} {
+		app.retryCount = retries
+		if got := app.retryDelay(); got != want {
+			t.Errorf("Expected %s after %d retries, got %s", want, retries, got)
+		}
+	}
+}
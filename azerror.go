@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// AzureCLIErrorPrefix starts the error lines the Azure CLI prints on stderr
+const AzureCLIErrorPrefix = "ERROR: "
+
+var (
+	aadstsCodePattern = regexp.MustCompile(`\bAADSTS\d+\b`)
+	// Azure Resource Manager codes come in parentheses before the message,
+	// or on a line of their own
+	armCodePattern = regexp.MustCompile(`(?m)^(?:ERROR: )?\((\w+)\)|^Code: (\w+)`)
+)
+
+// AzureCLIError is a failed az command, classified from what it printed on
+// stderr
+type AzureCLIError struct {
+	Type ErrorType
+	// Code is the AADSTS or Azure Resource Manager error code, if any
+	Code string
+	// Message is the first error line, without the "ERROR: " prefix
+	Message string
+	// Suggestion says how to resolve the error, when it is more specific than
+	// the one for its type
+	Suggestion string
+
+	Err error
+}
+
+func (e *AzureCLIError) Error() string {
+	return "azure CLI command failed: " + e.Message
+}
+
+func (e *AzureCLIError) Unwrap() error {
+	return e.Err
+}
+
+// azureCLIErrorRule classifies az errors with a given code, or whose message
+// starts with a given prefix
+type azureCLIErrorRule struct {
+	code       string
+	prefix     string
+	errType    ErrorType
+	suggestion string
+}
+
+// azureCLIErrorRules are tried in order; the first match wins. Errors with an
+// AADSTS code that no rule names are authentication errors, and the others
+// are unknown.
+var azureCLIErrorRules = []azureCLIErrorRule{
+	// Entra ID sign-in errors
+	{code: AADSTSMFARequired, errType: ErrorTypeAuth},
+	{code: AADSTSMFAExpired, errType: ErrorTypeAuth},
+	{code: "AADSTS53003", errType: ErrorTypePermission,
+		suggestion: "Access was blocked by a conditional access policy. Sign in from a compliant device or ask your administrator."},
+	{code: "AADSTS90002", errType: ErrorTypeConfig,
+		suggestion: "The tenant was not found. Check the tenant ID and the active Azure cloud."},
+
+	// Azure Resource Manager errors
+	{code: "AuthorizationFailed", errType: ErrorTypePermission},
+	{code: "ExpiredAuthenticationToken", errType: ErrorTypeAuth},
+	{code: "InvalidAuthenticationToken", errType: ErrorTypeAuth},
+	{code: "InvalidAuthenticationTokenTenant", errType: ErrorTypeAuth},
+	{code: "SubscriptionNotFound", errType: ErrorTypeConfig,
+		suggestion: "The subscription no longer exists or is in another Azure cloud. Reload the subscriptions or check the active cloud."},
+
+	// Azure CLI errors
+	{prefix: "Please run 'az login'", errType: ErrorTypeAuth},
+	{prefix: `Please run "az login"`, errType: ErrorTypeAuth},
+	{prefix: "User cancelled the Accounts Control Operation", errType: ErrorTypeAuth},
+	{prefix: "The subscription of '", errType: ErrorTypeConfig,
+		suggestion: "The subscription no longer exists or is in another Azure cloud. Reload the subscriptions or check the active cloud."},
+	{prefix: "The cloud '", errType: ErrorTypeConfig,
+		suggestion: "Check the registered clouds with 'az cloud list'."},
+	{prefix: "Please ensure you have network connection", errType: ErrorTypeNetwork},
+	{prefix: "HTTPSConnectionPool(", errType: ErrorTypeNetwork},
+	{prefix: "('Connection aborted.'", errType: ErrorTypeNetwork},
+}
+
+// azureCLIError describes a failed az command from the stderr captured by
+// exec.Cmd.Output
+func azureCLIError(err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		// az did not run, so there is no stderr to classify
+		return fmt.Errorf("azure CLI command failed: %w", err)
+	}
+	return parseAzureCLIError(err, exitErr.Stderr)
+}
+
+// parseAzureCLIError classifies the stderr of a failed az command into an
+// AzureCLIError wrapping err, itself wrapped in a ClaimsChallengeError when
+// the tenant asks for multi-factor authentication again
+func parseAzureCLIError(err error, stderr []byte) error {
+	cliErr := &AzureCLIError{Type: ErrorTypeUnknown, Message: azureCLIErrorMessage(stderr), Err: err}
+	if cliErr.Message == "" {
+		cliErr.Message = err.Error()
+	}
+
+	if code := aadstsCodePattern.Find(stderr); code != nil {
+		cliErr.Code = string(code)
+		cliErr.Type = ErrorTypeAuth
+	} else if code := armCodePattern.FindSubmatch(stderr); code != nil {
+		cliErr.Code = cmp.Or(string(code[1]), string(code[2]))
+	}
+
+	for _, rule := range azureCLIErrorRules {
+		if rule.code != "" && rule.code == cliErr.Code || rule.prefix != "" && strings.HasPrefix(cliErr.Message, rule.prefix) {
+			cliErr.Type = rule.errType
+			cliErr.Suggestion = rule.suggestion
+			break
+		}
+	}
+
+	return parseClaimsChallenge(cliErr, stderr)
+}
+
+// azureCLIErrorMessage returns the first error line az printed, or the first
+// line when none is marked as an error
+func azureCLIErrorMessage(stderr []byte) string {
+	var first string
+	for line := range bytes.Lines(stderr) {
+		text := strings.TrimSpace(string(line))
+		if message, ok := strings.CutPrefix(text, AzureCLIErrorPrefix); ok {
+			return message
+		}
+		if first == "" {
+			first = text
+		}
+	}
+	return first
+}
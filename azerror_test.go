@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// azureCLIErrorCorpus holds stderr printed by failing az commands
+var azureCLIErrorCorpus = []struct {
+	name     string
+	stderr   string
+	wantType ErrorType
+	wantCode string
+	// wantMessage starts the parsed message
+	wantMessage string
+	claims      bool
+}{
+	{
+		name:        "not logged in",
+		stderr:      "ERROR: Please run 'az login' to setup account.\n",
+		wantType:    ErrorTypeAuth,
+		wantMessage: "Please run 'az login' to setup account.",
+	},
+	{
+		name:        "not logged in, double quotes",
+		stderr:      "ERROR: Please run \"az login\" to access your accounts.\n",
+		wantType:    ErrorTypeAuth,
+		wantMessage: `Please run "az login" to access your accounts.`,
+	},
+	{
+		name:        "MFA required",
+		stderr:      mfaRequiredStderr,
+		wantType:    ErrorTypeAuth,
+		wantCode:    AADSTSMFARequired,
+		wantMessage: "AADSTS50076: Due to a configuration change",
+		claims:      true,
+	},
+	{
+		name:        "MFA expired",
+		stderr:      mfaExpiredStderr,
+		wantType:    ErrorTypeAuth,
+		wantCode:    AADSTSMFAExpired,
+		wantMessage: "AADSTS50078: Presented multi-factor authentication has expired",
+		claims:      true,
+	},
+	{
+		name: "refresh token expired",
+		stderr: `ERROR: AADSTS700082: The refresh token has expired due to inactivity. The token was issued on 2026-06-01T09:12:44.1020000Z and was inactive for 90.00:00:00. Trace ID: 1f2e3d4c-5b6a-4978-8a9b-0c1d2e3f4a5b Correlation ID: 6b7c8d9e-0f1a-4b2c-9d3e-4f5a6b7c8d9e Timestamp: 2026-10-02 09:01:12Z
+Interactive authentication is needed. Please run:
+az login --scope https://management.core.windows.net//.default
+`,
+		wantType:    ErrorTypeAuth,
+		wantCode:    "AADSTS700082",
+		wantMessage: "AADSTS700082: The refresh token has expired",
+	},
+	{
+		name:        "blocked by conditional access",
+		stderr:      "ERROR: AADSTS53003: Access has been blocked by Conditional Access policies. The access policy does not allow token issuance. Trace ID: 3c4d5e6f-7a8b-4c9d-8e0f-1a2b3c4d5e6f Correlation ID: 9e8d7c6b-5a4f-4e3d-a2c1-b0a9f8e7d6c5 Timestamp: 2026-10-02 09:05:40Z\n",
+		wantType:    ErrorTypePermission,
+		wantCode:    "AADSTS53003",
+		wantMessage: "AADSTS53003: Access has been blocked",
+	},
+	{
+		name:        "tenant not found",
+		stderr:      "ERROR: AADSTS90002: Tenant 'contoso.onmicrosoft.us' not found. Check to make sure you have the correct tenant ID and are signing into the correct cloud. Trace ID: 7a6b5c4d-3e2f-4a1b-8c9d-0e1f2a3b4c5d Correlation ID: 0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d Timestamp: 2026-10-02 09:07:02Z\n",
+		wantType:    ErrorTypeConfig,
+		wantCode:    "AADSTS90002",
+		wantMessage: "AADSTS90002: Tenant 'contoso.onmicrosoft.us' not found.",
+	},
+	{
+		name:        "subscription does not exist",
+		stderr:      "ERROR: The subscription of '3f50c9e1-8f3b-4d1e-9a2b-5b8e4b4e5f1a' doesn't exist in cloud 'AzureCloud'.\n",
+		wantType:    ErrorTypeConfig,
+		wantMessage: "The subscription of '3f50c9e1-8f3b-4d1e-9a2b-5b8e4b4e5f1a' doesn't exist",
+	},
+	{
+		name:        "cloud not registered",
+		stderr:      "ERROR: The cloud 'AzureStackQa' is not registered.\n",
+		wantType:    ErrorTypeConfig,
+		wantMessage: "The cloud 'AzureStackQa' is not registered.",
+	},
+	{
+		name: "authorization failed",
+		stderr: `ERROR: (AuthorizationFailed) The client 'jane@contoso.com' with object id '0d3c5e7a-1b2c-4d3e-8f4a-5b6c7d8e9f0a' does not have authorization to perform action 'Microsoft.Resources/subscriptions/read' over scope '/subscriptions/3f50c9e1-8f3b-4d1e-9a2b-5b8e4b4e5f1a' or the scope is invalid.
+Code: AuthorizationFailed
+Message: The client 'jane@contoso.com' with object id '0d3c5e7a-1b2c-4d3e-8f4a-5b6c7d8e9f0a' does not have authorization to perform action 'Microsoft.Resources/subscriptions/read' over scope '/subscriptions/3f50c9e1-8f3b-4d1e-9a2b-5b8e4b4e5f1a' or the scope is invalid.
+`,
+		wantType:    ErrorTypePermission,
+		wantCode:    "AuthorizationFailed",
+		wantMessage: "(AuthorizationFailed) The client 'jane@contoso.com'",
+	},
+	{
+		name: "expired token",
+		stderr: `ERROR: The access token expiry UTC time '10/2/2026 8:00:00 AM' is earlier than current UTC time '10/2/2026 9:12:31 AM'.
+Code: ExpiredAuthenticationToken
+Message: The access token expiry UTC time '10/2/2026 8:00:00 AM' is earlier than current UTC time '10/2/2026 9:12:31 AM'.
+`,
+		wantType:    ErrorTypeAuth,
+		wantCode:    "ExpiredAuthenticationToken",
+		wantMessage: "The access token expiry UTC time",
+	},
+	{
+		name:        "no network",
+		stderr:      "ERROR: HTTPSConnectionPool(host='management.azure.com', port=443): Max retries exceeded with url: /subscriptions?api-version=2022-12-01 (Caused by NewConnectionError('<urllib3.connection.HTTPSConnection object at 0x7f3a2c1d4e50>: Failed to establish a new connection: [Errno -3] Temporary failure in name resolution'))\n",
+		wantType:    ErrorTypeNetwork,
+		wantMessage: "HTTPSConnectionPool(host='management.azure.com', port=443)",
+	},
+	{
+		name:        "no network for a token",
+		stderr:      "ERROR: Please ensure you have network connection. Error detail: HTTPSConnectionPool(host='login.microsoftonline.com', port=443): Max retries exceeded with url: /organizations/v2.0/.well-known/openid-configuration\n",
+		wantType:    ErrorTypeNetwork,
+		wantMessage: "Please ensure you have network connection.",
+	},
+	{
+		name:        "connection reset",
+		stderr:      "ERROR: ('Connection aborted.', ConnectionResetError(104, 'Connection reset by peer'))\n",
+		wantType:    ErrorTypeNetwork,
+		wantMessage: "('Connection aborted.'",
+	},
+	{
+		name:        "warnings before the error",
+		stderr:      "WARNING: A few accounts are skipped as they don't have 'Enabled' state.\nERROR: Please run 'az login' to setup account.\n",
+		wantType:    ErrorTypeAuth,
+		wantMessage: "Please run 'az login' to setup account.",
+	},
+	{
+		name:        "unknown error",
+		stderr:      "ERROR: The command failed with an unexpected error. Here is the traceback:\nKeyError: 'accessToken'\n",
+		wantType:    ErrorTypeUnknown,
+		wantMessage: "The command failed with an unexpected error.",
+	},
+	{
+		name:        "no stderr",
+		stderr:      "",
+		wantType:    ErrorTypeUnknown,
+		wantMessage: "exit status 1",
+	},
+}
+
+func TestParseAzureCLIError(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+
+	for _, tt := range azureCLIErrorCorpus {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseAzureCLIError(exitErr, []byte(tt.stderr))
+
+			var cliErr *AzureCLIError
+			if !errors.As(err, &cliErr) {
+				t.Fatalf("Expected an AzureCLIError, got %v", err)
+			}
+			if cliErr.Type != tt.wantType || cliErr.Code != tt.wantCode {
+				t.Errorf("Expected type %v and code %q, got %v and %q", tt.wantType, tt.wantCode, cliErr.Type, cliErr.Code)
+			}
+			if !strings.HasPrefix(cliErr.Message, tt.wantMessage) {
+				t.Errorf("Expected message starting with %q, got %q", tt.wantMessage, cliErr.Message)
+			}
+			if !errors.Is(err, exitErr) {
+				t.Error("Expected the command error wrapped")
+			}
+
+			var challenge *ClaimsChallengeError
+			if errors.As(err, &challenge) != tt.claims {
+				t.Errorf("Expected claims challenge %v, got %v", tt.claims, err)
+			}
+		})
+	}
+}
+
+func TestApp_ClassifyAzureCLIError(t *testing.T) {
+	app := NewApp(NewSampleProvider())
+	exitErr := errors.New("exit status 1")
+
+	for _, tt := range azureCLIErrorCorpus {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseAzureCLIError(exitErr, []byte(tt.stderr))
+			appErr := app.classifyError(err)
+
+			if appErr.Type != tt.wantType {
+				t.Errorf("Expected error type %v, got %v", tt.wantType, appErr.Type)
+			}
+			if retryable := tt.wantType == ErrorTypeNetwork || tt.wantType == ErrorTypeUnknown; appErr.Retryable != retryable {
+				t.Errorf("Expected retryable %v, got %v", retryable, appErr.Retryable)
+			}
+			if appErr.Suggestion == "" {
+				t.Error("Expected non-empty suggestion")
+			}
+		})
+	}
+}
+
+func TestAzureCLIError_NotRun(t *testing.T) {
+	_, err := exec.Command("asubselect-missing-az").Output()
+
+	err = azureCLIError(err)
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Fatalf("Expected exec.ErrNotFound wrapped, got %v", err)
+	}
+	if appErr := NewApp(NewSampleProvider()).classifyError(err); appErr.Type != ErrorTypeConfig {
+		t.Errorf("Expected a configuration error, got %v", appErr.Type)
+	}
+}
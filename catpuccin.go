@@ -1,9 +1,9 @@
-// Package main defines the Catppuccin Mocha color palette for the application.
+// Package main defines the Catppuccin color palettes for the application.
 package main
 
 import "github.com/charmbracelet/lipgloss"
 
-// Catppuccin Mocha color palette
+// Catppuccin Mocha color palette, the default flavor
 // Reference: https://github.com/catppuccin/catppuccin/tree/main
 const (
 	// Base colors
@@ -45,3 +45,110 @@ const (
 	Error          = Red
 	Info           = Teal
 )
+
+// Flavor names
+const (
+	FlavorLatte     = "latte"
+	FlavorFrappe    = "frappe"
+	FlavorMacchiato = "macchiato"
+	FlavorMocha     = "mocha"
+)
+
+// Palette holds the colors of a Catppuccin flavor
+type Palette struct {
+	Rosewater, Flamingo, Pink, Mauve, Red, Maroon, Peach       lipgloss.Color
+	Yellow, Green, Teal, Sky, Sapphire, Blue, Lavender         lipgloss.Color
+	Text, Subtext1, Subtext0                                   lipgloss.Color
+	Overlay2, Overlay1, Overlay0, Surface2, Surface1, Surface0 lipgloss.Color
+	Base, Mantle, Crust                                        lipgloss.Color
+}
+
+// colors maps the palette's entry names, as used in the configuration, to its colors
+func (p *Palette) colors() map[string]*lipgloss.Color {
+	return map[string]*lipgloss.Color{
+		"rosewater": &p.Rosewater,
+		"flamingo":  &p.Flamingo,
+		"pink":      &p.Pink,
+		"mauve":     &p.Mauve,
+		"red":       &p.Red,
+		"maroon":    &p.Maroon,
+		"peach":     &p.Peach,
+		"yellow":    &p.Yellow,
+		"green":     &p.Green,
+		"teal":      &p.Teal,
+		"sky":       &p.Sky,
+		"sapphire":  &p.Sapphire,
+		"blue":      &p.Blue,
+		"lavender":  &p.Lavender,
+		"text":      &p.Text,
+		"subtext1":  &p.Subtext1,
+		"subtext0":  &p.Subtext0,
+		"overlay2":  &p.Overlay2,
+		"overlay1":  &p.Overlay1,
+		"overlay0":  &p.Overlay0,
+		"surface2":  &p.Surface2,
+		"surface1":  &p.Surface1,
+		"surface0":  &p.Surface0,
+		"base":      &p.Base,
+		"mantle":    &p.Mantle,
+		"crust":     &p.Crust,
+	}
+}
+
+// Catppuccin flavors, from lightest to darkest
+var (
+	LattePalette = Palette{
+		Rosewater: "#dc8a78", Flamingo: "#dd7878", Pink: "#ea76cb", Mauve: "#8839ef",
+		Red: "#d20f39", Maroon: "#e64553", Peach: "#fe640b", Yellow: "#df8e1d",
+		Green: "#40a02b", Teal: "#179299", Sky: "#04a5e5", Sapphire: "#209fb5",
+		Blue: "#1e66f5", Lavender: "#7287fd",
+		Text: "#4c4f69", Subtext1: "#5c5f77", Subtext0: "#6c6f85",
+		Overlay2: "#7c7f93", Overlay1: "#8c8fa1", Overlay0: "#9ca0b0",
+		Surface2: "#acb0be", Surface1: "#bcc0cc", Surface0: "#ccd0da",
+		Base: "#eff1f5", Mantle: "#e6e9ef", Crust: "#dce0e8",
+	}
+
+	FrappePalette = Palette{
+		Rosewater: "#f2d5cf", Flamingo: "#eebebe", Pink: "#f4b8e4", Mauve: "#ca9ee6",
+		Red: "#e78284", Maroon: "#ea999c", Peach: "#ef9f76", Yellow: "#e5c890",
+		Green: "#a6d189", Teal: "#81c8be", Sky: "#99d1db", Sapphire: "#85c1dc",
+		Blue: "#8caaee", Lavender: "#babbf1",
+		Text: "#c6d0f5", Subtext1: "#b5bfe2", Subtext0: "#a5adce",
+		Overlay2: "#949cbb", Overlay1: "#838ba7", Overlay0: "#737994",
+		Surface2: "#626880", Surface1: "#51576d", Surface0: "#414559",
+		Base: "#303446", Mantle: "#292c3c", Crust: "#232634",
+	}
+
+	MacchiatoPalette = Palette{
+		Rosewater: "#f4dbd6", Flamingo: "#f0c6c6", Pink: "#f5bde6", Mauve: "#c6a0f6",
+		Red: "#ed8796", Maroon: "#ee99a0", Peach: "#f5a97f", Yellow: "#eed49f",
+		Green: "#a6da95", Teal: "#8bd5ca", Sky: "#91d7e3", Sapphire: "#7dc4e4",
+		Blue: "#8aadf4", Lavender: "#b7bdf8",
+		Text: "#cad3f5", Subtext1: "#b8c0e0", Subtext0: "#a5adcb",
+		Overlay2: "#939ab7", Overlay1: "#8087a2", Overlay0: "#6e738d",
+		Surface2: "#5b6078", Surface1: "#494d64", Surface0: "#363a4f",
+		Base: "#24273a", Mantle: "#1e2030", Crust: "#181926",
+	}
+
+	MochaPalette = Palette{
+		Rosewater: Rosewater, Flamingo: Flamingo, Pink: Pink, Mauve: Mauve,
+		Red: Red, Maroon: Maroon, Peach: Peach, Yellow: Yellow,
+		Green: Green, Teal: Teal, Sky: Sky, Sapphire: Sapphire,
+		Blue: Blue, Lavender: Lavender,
+		Text: Text, Subtext1: Subtext1, Subtext0: Subtext0,
+		Overlay2: Overlay2, Overlay1: Overlay1, Overlay0: Overlay0,
+		Surface2: Surface2, Surface1: Surface1, Surface0: Surface0,
+		Base: Base, Mantle: Mantle, Crust: Crust,
+	}
+)
+
+// flavors maps flavor names to their palettes
+var flavors = map[string]Palette{
+	FlavorLatte:     LattePalette,
+	FlavorFrappe:    FrappePalette,
+	FlavorMacchiato: MacchiatoPalette,
+	FlavorMocha:     MochaPalette,
+}
+
+// flavorNames lists the flavors in order, for help and error messages
+var flavorNames = []string{FlavorLatte, FlavorFrappe, FlavorMacchiato, FlavorMocha}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+	"math"
 	"testing"
 
 	"github.com/charmbracelet/lipgloss"
@@ -174,3 +176,78 @@ func TestGlobalVariables(t *testing.T) {
 	width = originalWidth
 	height = originalHeight
 }
+
+// relativeLuminance returns the WCAG relative luminance of a hex color
+func relativeLuminance(t *testing.T, color lipgloss.Color) float64 {
+	t.Helper()
+
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(string(color), "#%02x%02x%02x", &r, &g, &b); err != nil {
+		t.Fatalf("Invalid color %q: %v", color, err)
+	}
+
+	linear := func(channel uint8) float64 {
+		c := float64(channel) / 255
+		if c <= 0.03928 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(r) + 0.7152*linear(g) + 0.0722*linear(b)
+}
+
+// contrastRatio returns the WCAG contrast ratio between two hex colors
+func contrastRatio(t *testing.T, a, b lipgloss.Color) float64 {
+	t.Helper()
+
+	la, lb := relativeLuminance(t, a), relativeLuminance(t, b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+func TestThemeContrast(t *testing.T) {
+	// WCAG AA asks for 4.5:1 for body text and 3:1 for large or decorative text
+	const minimumText, minimumLarge = 4.5, 3.0
+
+	for _, name := range flavorNames {
+		theme := NewTheme(name, flavors[name])
+		background := theme.Palette.Base
+
+		checks := []struct {
+			role       string
+			foreground lipgloss.Color
+			background lipgloss.Color
+			minimum    float64
+		}{
+			{"text", theme.Text, background, minimumText},
+			{"subtext", theme.Subtext, background, minimumText},
+			{"title", theme.Text, theme.TitleBackground, minimumText},
+			{"error", theme.Error, background, minimumLarge},
+			{"success", theme.Success, background, minimumLarge},
+			{"info", theme.Info, background, minimumLarge},
+			{"header", theme.Header, background, minimumLarge},
+			{"accent", theme.Accent, background, minimumLarge},
+			{"muted", theme.Muted, background, minimumLarge},
+		}
+
+		for _, check := range checks {
+			if ratio := contrastRatio(t, check.foreground, check.background); ratio < check.minimum {
+				t.Errorf("%s: %s contrast %.2f:1 is below %.1f:1", name, check.role, ratio, check.minimum)
+			}
+		}
+	}
+}
+
+func TestThemeContrast_AutoFlavors(t *testing.T) {
+	// The flavors picked automatically must be readable on a matching terminal
+	white, black := lipgloss.Color("#ffffff"), lipgloss.Color("#000000")
+
+	if ratio := contrastRatio(t, LattePalette.Text, white); ratio < 4.5 {
+		t.Errorf("Expected %s text to be readable on white, got %.2f:1", LightThemeName, ratio)
+	}
+	if ratio := contrastRatio(t, MochaPalette.Text, black); ratio < 4.5 {
+		t.Errorf("Expected %s text to be readable on black, got %.2f:1", DarkThemeName, ratio)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Claims challenge configuration
+const (
+	// KeyReauth logs in again to the highlighted subscription's tenant
+	KeyReauth = "L"
+
+	// LockMarker is shown before subscriptions whose tenant needs a new login
+	LockMarker = "🔒 "
+
+	// DefaultLoginScope is the scope 'az login' suggests for Azure Resource Manager
+	DefaultLoginScope = "https://management.core.windows.net//.default"
+)
+
+// AADSTS codes asking for a fresh multi-factor authentication
+const (
+	AADSTSMFARequired = "AADSTS50076"
+	AADSTSMFAExpired  = "AADSTS50078"
+)
+
+var (
+	claimsCodePattern   = regexp.MustCompile(`\b(` + AADSTSMFARequired + `|` + AADSTSMFAExpired + `)\b`)
+	claimsTenantPattern = regexp.MustCompile(`--tenant[ =]["']?([0-9A-Za-z.-]+)["']?`)
+	claimsScopePattern  = regexp.MustCompile(`--scope[ =]["']?([^\s"']+)["']?`)
+)
+
+// ClaimsChallengeError is returned when a tenant requires multi-factor
+// authentication again, as conditional access does once the refresh token
+// for that tenant no longer satisfies its policies
+type ClaimsChallengeError struct {
+	// Code is the AADSTS error code
+	Code string
+	// TenantID is the tenant to log in to, and Scope the scope to request
+	TenantID string
+	Scope    string
+
+	Err error
+}
+
+func (e *ClaimsChallengeError) Error() string {
+	message := "multi-factor authentication required"
+	if e.Code == AADSTSMFAExpired {
+		message = "multi-factor authentication expired"
+	}
+	if e.TenantID != "" {
+		message += " for tenant " + e.TenantID
+	}
+	return fmt.Sprintf("%s: %s", e.Code, message)
+}
+
+func (e *ClaimsChallengeError) Unwrap() error {
+	return e.Err
+}
+
+// parseClaimsChallenge returns a ClaimsChallengeError wrapping err when the
+// Azure CLI's stderr reports AADSTS50076 or AADSTS50078. The tenant and scope
+// come from the 'az login' command it suggests, if any.
+func parseClaimsChallenge(err error, stderr []byte) error {
+	code := claimsCodePattern.FindSubmatch(stderr)
+	if code == nil {
+		return err
+	}
+
+	challenge := &ClaimsChallengeError{Code: string(code[1]), Scope: DefaultLoginScope, Err: err}
+	if tenant := claimsTenantPattern.FindSubmatch(stderr); tenant != nil {
+		challenge.TenantID = string(tenant[1])
+	}
+	if scope := claimsScopePattern.FindSubmatch(stderr); scope != nil {
+		challenge.Scope = string(scope[1])
+	}
+	return challenge
+}
+
+// lockTenant records a claims challenge for the tenant it names, or else
+// for the subscription's tenant
+func (app *App) lockTenant(err error, subscription Subscription) {
+	var challenge *ClaimsChallengeError
+	if !errors.As(err, &challenge) {
+		return
+	}
+	challenge.TenantID = cmp.Or(challenge.TenantID, subscription.TenantID)
+	if challenge.TenantID != "" {
+		app.lockedTenants[challenge.TenantID] = challenge
+	}
+}
+
+// isLocked reports whether the subscription's tenant needs a new login
+func (app *App) isLocked(subscription Subscription) bool {
+	return app.lockedTenants[subscription.TenantID] != nil
+}
+
+// reauthenticate logs in again to the highlighted subscription's tenant
+func (app *App) reauthenticate() tea.Cmd {
+	sub, ok := app.list.SelectedItem().(Subscription)
+	if !ok || !app.isLocked(sub) {
+		return nil
+	}
+
+	challenge := app.lockedTenants[sub.TenantID]
+	return app.login(challenge.TenantID, challenge.Scope)
+}
+
+// loginTarget returns the tenant and scope to log in to for err: those of a
+// claims challenge, or else tenantID without a scope
+func loginTarget(err error, tenantID string) (string, string) {
+	var challenge *ClaimsChallengeError
+	if errors.As(err, &challenge) {
+		return cmp.Or(challenge.TenantID, tenantID), challenge.Scope
+	}
+	return tenantID, ""
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// mfaRequiredStderr is what 'az account set' prints when a tenant's
+// conditional access asks for multi-factor authentication
+const mfaRequiredStderr = `ERROR: AADSTS50076: Due to a configuration change made by your administrator, or because you moved to a new location, you must use multi-factor authentication to access '797f4846-ba00-4fd7-ba43-dac1f8f63013'. Trace ID: 5b1c3e1a-0d4e-4a55-b1a3-4f5b1e0c2d00 Correlation ID: 8f0c2b9e-2f6c-4a0e-9a61-1c7d3e5f9b11 Timestamp: 2026-10-02 08:14:55Z
+Interactive authentication is needed. Please run:
+az login --tenant "t-2" --scope "https://management.core.windows.net//.default"
+`
+
+// mfaExpiredStderr is printed once the multi-factor authentication has expired
+const mfaExpiredStderr = `ERROR: AADSTS50078: Presented multi-factor authentication has expired due to policies configured by your administrator, you must refresh your multi-factor authentication to access '797f4846-ba00-4fd7-ba43-dac1f8f63013'. Trace ID: 0e4f2d8b-7a4c-4b3e-8d2f-6a1b9c0d3e22 Correlation ID: 2c7e9a41-5b3d-4f6e-a8c0-d1e2f3a4b5c6 Timestamp: 2026-10-02 08:20:03Z
+Run the command below to authenticate interactively; additional arguments may be added as needed:
+az logout
+az login --tenant "t-3" --scope "https://management.usgovcloudapi.net//.default"
+`
+
+func TestParseClaimsChallenge(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+	tests := []struct {
+		name   string
+		stderr string
+		want   *ClaimsChallengeError
+	}{
+		{name: "required", stderr: mfaRequiredStderr,
+			want: &ClaimsChallengeError{Code: AADSTSMFARequired, TenantID: "t-2", Scope: DefaultLoginScope}},
+		{name: "expired", stderr: mfaExpiredStderr,
+			want: &ClaimsChallengeError{Code: AADSTSMFAExpired, TenantID: "t-3", Scope: "https://management.usgovcloudapi.net//.default"}},
+		{name: "no login command", stderr: "ERROR: AADSTS50076: you must use multi-factor authentication",
+			want: &ClaimsChallengeError{Code: AADSTSMFARequired, Scope: DefaultLoginScope}},
+		{name: "other error", stderr: "ERROR: Please run 'az login' to setup account."},
+		{name: "other code", stderr: "ERROR: AADSTS500761: unrelated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseClaimsChallenge(exitErr, []byte(tt.stderr))
+			var got *ClaimsChallengeError
+			if !errors.As(err, &got) {
+				if tt.want != nil {
+					t.Fatalf("Expected a claims challenge, got %v", err)
+				}
+				if err != exitErr {
+					t.Errorf("Expected the error unchanged, got %v", err)
+				}
+				return
+			}
+			if tt.want == nil {
+				t.Fatalf("Expected no claims challenge, got %v", got)
+			}
+			if got.Code != tt.want.Code || got.TenantID != tt.want.TenantID || got.Scope != tt.want.Scope {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+			if !errors.Is(err, exitErr) {
+				t.Error("Expected the claims challenge to wrap the command error")
+			}
+		})
+	}
+}
+
+func TestAzureCLIError_ClaimsChallenge(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+
+	_, err = exec.Command(sh, "-c", "printf '%s' \"$0\" >&2; exit 1", mfaRequiredStderr).Output()
+	var challenge *ClaimsChallengeError
+	if !errors.As(azureCLIError(err), &challenge) || challenge.TenantID != "t-2" {
+		t.Errorf("Expected a claims challenge for t-2 from stderr, got %v", azureCLIError(err))
+	}
+}
+
+func TestApp_ClaimsChallenge(t *testing.T) {
+	provider := NewFakeProvider(groupTestSubscriptions)
+	// The CLI names no tenant, so the failing subscription's is used
+	provider.SetErr = &ClaimsChallengeError{Code: AADSTSMFAExpired, Scope: DefaultLoginScope}
+	app := NewApp(provider)
+	app.config.AzureCommand = "go"
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+
+	app.list.Select(1)
+	locked := app.list.SelectedItem().(Subscription)
+	app.Update(app.changeSubscription(locked)())
+	if app.state != StateError || !app.canLogin() {
+		t.Fatalf("Expected a login offered, got %v with %v", app.state, app.err)
+	}
+	if appErr := app.classifyError(app.err); appErr.Retryable || !strings.Contains(appErr.Suggestion, "--tenant "+locked.TenantID) {
+		t.Errorf("Expected a non-retryable error suggesting a login to %s, got %+v", locked.TenantID, appErr)
+	}
+
+	// Back in the list, the tenant's subscriptions are locked
+	app.Update(BackMsg{})
+	if !app.isLocked(locked) {
+		t.Fatal("Expected the tenant locked")
+	}
+	for _, item := range app.list.Items() {
+		sub := item.(Subscription)
+		if locked := sub.TenantID == locked.TenantID; strings.Contains(app.itemPrefix(sub), LockMarker) != locked {
+			t.Errorf("Expected %s locked: %v", sub.ID, locked)
+		}
+	}
+	if view := app.View(); !strings.Contains(view, LockMarker) {
+		t.Errorf("Expected the lock shown, got:\n%s", view)
+	}
+
+	// Unlocked subscriptions have nothing to log in to
+	app.list.Select(0)
+	if sub := app.list.SelectedItem().(Subscription); app.isLocked(sub) {
+		t.Fatalf("Expected %s unlocked", sub.ID)
+	}
+	if _, cmd := app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyReauth)}); cmd != nil {
+		t.Error("Expected no login for an unlocked subscription")
+	}
+
+	app.list.Select(1)
+	_, cmd := app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyReauth)})
+	if cmd == nil || app.failedTenant != locked.TenantID {
+		t.Fatalf("Expected a login to %s, got %q", locked.TenantID, app.failedTenant)
+	}
+
+	// Logging in lifts the lock
+	provider.SetErr = nil
+	app.Update(LoginFinishedMsg{})
+	if app.isLocked(locked) {
+		t.Error("Expected the lock lifted after logging in")
+	}
+}
+
+func TestApp_ClaimsChallenge_Load(t *testing.T) {
+	provider := NewFakeProvider(groupTestSubscriptions)
+	app := NewApp(provider)
+	app.config.AzureCommand = "go"
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+
+	provider.ListErr = parseAzureCLIError(errors.New("exit status 1"), []byte(mfaRequiredStderr))
+	app.Update(app.loadSubscriptions())
+	if app.state != StateError || !app.isLocked(Subscription{TenantID: "t-2"}) {
+		t.Fatalf("Expected tenant t-2 locked, got %v with %v", app.state, app.err)
+	}
+
+	if tenant, scope := loginTarget(app.err, app.failedTenant); tenant != "t-2" || scope != DefaultLoginScope {
+		t.Errorf("Expected a login to t-2 with the default scope, got %q, %q", tenant, scope)
+	}
+	if _, cmd := app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyLogin)}); cmd == nil || app.failedTenant != "t-2" {
+		t.Errorf("Expected a login to t-2, got %q", app.failedTenant)
+	}
+}
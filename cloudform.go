@@ -0,0 +1,341 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Clouds screen configuration
+const (
+	// KeyClouds opens the clouds screen, KeyAddCloud the registration form
+	KeyClouds   = "c"
+	KeyAddCloud = "a"
+
+	CloudListTitle         = "Azure Clouds"
+	CloudFormTitle         = "Register a custom cloud"
+	ActiveCloudMarker      = "● "
+	CloudRegisteredMessage = "Registered cloud %s"
+)
+
+// ErrCloudsUnsupported is reported when the provider cannot list clouds
+var ErrCloudsUnsupported = errors.New("clouds are not available with this provider")
+
+// cloudFormKeys move between the fields of the cloud form
+var cloudFormKeys = struct {
+	Next, Previous key.Binding
+}{
+	Next:     key.NewBinding(key.WithKeys("tab", "down"), key.WithHelp("tab", "next field")),
+	Previous: key.NewBinding(key.WithKeys("shift+tab", "up"), key.WithHelp("shift+tab", "previous field")),
+}
+
+// CloudsLoadedMsg is sent when the registered clouds have been listed
+type CloudsLoadedMsg struct {
+	Clouds []Cloud
+	Error  error
+}
+
+// CloudAddedMsg is sent when a cloud registration has been attempted
+type CloudAddedMsg struct {
+	Cloud Cloud
+	Error error
+}
+
+// cloudItem is a row in the clouds list
+type cloudItem struct {
+	Cloud
+}
+
+// Title implements list.DefaultItem interface
+func (c cloudItem) Title() string {
+	if c.IsActive {
+		return ActiveCloudMarker + c.Name
+	}
+	return c.Name
+}
+
+// Description implements list.DefaultItem interface
+func (c cloudItem) Description() string {
+	return joinNonEmpty(" ", c.ResourceManager, "("+c.Profile+")")
+}
+
+// FilterValue implements list.Item interface
+func (c cloudItem) FilterValue() string {
+	return joinNonEmpty("/", c.Name, c.ResourceManager)
+}
+
+// Cloud form fields, in order
+const (
+	cloudFieldName = iota
+	cloudFieldMetadata
+	cloudFieldResourceManager
+	cloudFieldProfile
+)
+
+// cloudFieldLabels label the cloud form fields
+var cloudFieldLabels = []string{
+	cloudFieldName:            "Name",
+	cloudFieldMetadata:        "Metadata file",
+	cloudFieldResourceManager: "Resource manager endpoint",
+	cloudFieldProfile:         "API profile",
+}
+
+// CloudForm is the guided form registering a custom cloud, such as an Azure
+// Stack Hub, from a saved response of its ARM metadata endpoint
+type CloudForm struct {
+	inputs []textinput.Model
+	focus  int
+	err    error
+	theme  Theme
+}
+
+// NewCloudForm creates an empty cloud form with the first field focused
+func NewCloudForm(theme Theme) *CloudForm {
+	placeholders := []string{
+		cloudFieldName:            "AzureStackUser",
+		cloudFieldMetadata:        "~/Downloads/endpoints.json",
+		cloudFieldResourceManager: "https://management.local.azurestack.external (if not in the file)",
+		cloudFieldProfile:         DefaultCloudProfile + " (or e.g. 2020-09-01-hybrid)",
+	}
+
+	form := &CloudForm{theme: theme}
+	for _, placeholder := range placeholders {
+		input := textinput.New()
+		input.Prompt = ""
+		input.Placeholder = placeholder
+		input.TextStyle = lipgloss.NewStyle().Foreground(theme.Text)
+		input.PlaceholderStyle = lipgloss.NewStyle().Foreground(theme.Muted)
+		input.Cursor.Style = lipgloss.NewStyle().Foreground(theme.Accent)
+		form.inputs = append(form.inputs, input)
+	}
+	form.inputs[0].Focus()
+
+	return form
+}
+
+// Init initializes the cloud form
+func (f *CloudForm) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// Update moves between fields and types into the focused one
+func (f *CloudForm) Update(msg tea.Msg) (*CloudForm, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, cloudFormKeys.Next):
+			return f, f.setFocus(f.focus + 1)
+		case key.Matches(msg, cloudFormKeys.Previous):
+			return f, f.setFocus(f.focus - 1)
+		}
+	}
+
+	var cmd tea.Cmd
+	f.inputs[f.focus], cmd = f.inputs[f.focus].Update(msg)
+	return f, cmd
+}
+
+// setFocus focuses the field at index, wrapping around
+func (f *CloudForm) setFocus(index int) tea.Cmd {
+	f.inputs[f.focus].Blur()
+	f.focus = (index + len(f.inputs)) % len(f.inputs)
+	return f.inputs[f.focus].Focus()
+}
+
+// lastField reports whether the last field has focus
+func (f *CloudForm) lastField() bool {
+	return f.focus == len(f.inputs)-1
+}
+
+// value returns the trimmed value of a field
+func (f *CloudForm) value(field int) string {
+	return strings.TrimSpace(f.inputs[field].Value())
+}
+
+// Cloud reads the metadata file and returns the cloud to register
+func (f *CloudForm) Cloud() (Cloud, error) {
+	name := f.value(cloudFieldName)
+	if name == "" || strings.ContainsAny(name, "[] \t") {
+		return Cloud{}, errors.New("enter a name without spaces or brackets")
+	}
+
+	path := f.value(cloudFieldMetadata)
+	if path == "" {
+		return Cloud{}, errors.New("enter the path of the saved metadata endpoint response")
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Cloud{}, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	cloud, err := parseCloudMetadata(data, name, f.value(cloudFieldResourceManager))
+	if err != nil {
+		return Cloud{}, err
+	}
+	if profile := f.value(cloudFieldProfile); profile != "" {
+		cloud.Profile = profile
+	}
+	return cloud, nil
+}
+
+// View renders the cloud form
+func (f *CloudForm) View() string {
+	theme := f.theme
+	label := lipgloss.NewStyle().Foreground(theme.Subtext).Width(28)
+	focused := label.Foreground(theme.Accent).Bold(true)
+
+	lines := []string{lipgloss.NewStyle().Foreground(theme.Header).Bold(true).Render(CloudFormTitle), ""}
+	for i, input := range f.inputs {
+		style := label
+		if i == f.focus {
+			style = focused
+		}
+		lines = append(lines, style.Render(cloudFieldLabels[i])+input.View())
+	}
+
+	lines = append(lines, "")
+	if f.err != nil {
+		lines = append(lines, lipgloss.NewStyle().Foreground(theme.Error).Render(f.err.Error()), "")
+	}
+	lines = append(lines, lipgloss.NewStyle().Foreground(theme.Muted).Render("tab next field • enter next field / register • esc cancel"))
+
+	return docStyle.Render(strings.Join(lines, "\n"))
+}
+
+// initializeCloudList sets up the list of the clouds screen
+func (app *App) initializeCloudList() {
+	cloudList := list.New([]list.Item{}, app.createListDelegate(), 0, 0)
+
+	app.styleList(&cloudList)
+	cloudList.Title = CloudListTitle
+	app.applyKeyMap(&cloudList)
+	cloudList.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{app.keys.AddCloud}
+	}
+
+	h, v := docStyle.GetFrameSize()
+	cloudList.SetSize(width-h, height-v)
+
+	app.cloudList = cloudList
+}
+
+// showClouds switches to the clouds screen and lists the registered clouds
+func (app *App) showClouds() tea.Cmd {
+	registry, ok := providerAs[CloudRegistry](app.provider)
+	if !ok {
+		return app.list.NewStatusMessage(ErrCloudsUnsupported.Error())
+	}
+
+	app.state = StateManagingClouds
+	return func() tea.Msg {
+		clouds, err := registry.Clouds()
+		return CloudsLoadedMsg{Clouds: clouds, Error: err}
+	}
+}
+
+// handleCloudsLoaded fills the clouds list, highlighting the active cloud
+func (app *App) handleCloudsLoaded(msg CloudsLoadedMsg) (tea.Model, tea.Cmd) {
+	if msg.Error != nil {
+		cmd := app.cloudList.SetItems(nil)
+		return app, tea.Batch(cmd, app.cloudList.NewStatusMessage(fmt.Sprintf("Failed to list clouds: %v", msg.Error)))
+	}
+
+	items := make([]list.Item, len(msg.Clouds))
+	for i, cloud := range msg.Clouds {
+		items[i] = cloudItem{cloud}
+	}
+	cmd := app.cloudList.SetItems(items)
+	if index := slices.IndexFunc(msg.Clouds, func(c Cloud) bool { return c.IsActive }); index >= 0 {
+		app.cloudList.Select(index)
+	}
+	return app, cmd
+}
+
+// showCloudForm opens the form registering a custom cloud
+func (app *App) showCloudForm() tea.Cmd {
+	app.cloudForm = NewCloudForm(app.theme)
+	app.state = StateAddingCloud
+	return app.cloudForm.Init()
+}
+
+// submitCloudForm registers the cloud described by the form
+func (app *App) submitCloudForm() tea.Cmd {
+	cloud, err := app.cloudForm.Cloud()
+	if err != nil {
+		app.cloudForm.err = err
+		return nil
+	}
+
+	registry, ok := providerAs[CloudRegistry](app.provider)
+	if !ok {
+		app.cloudForm.err = ErrCloudsUnsupported
+		return nil
+	}
+
+	app.cloudForm.err = nil
+	return func() tea.Msg {
+		return CloudAddedMsg{Cloud: cloud, Error: registry.RegisterCloud(cloud)}
+	}
+}
+
+// handleCloudAdded returns to the clouds screen once a cloud is registered,
+// or shows the error in the form
+func (app *App) handleCloudAdded(msg CloudAddedMsg) (tea.Model, tea.Cmd) {
+	if msg.Error != nil {
+		if app.cloudForm != nil {
+			app.cloudForm.err = msg.Error
+		}
+		return app, nil
+	}
+
+	app.cloudForm = nil
+	return app, tea.Batch(
+		app.showClouds(),
+		app.cloudList.NewStatusMessage(fmt.Sprintf(CloudRegisteredMessage, msg.Cloud.Name)),
+	)
+}
+
+// handleCloudKey handles keys on the clouds screen and in the cloud form
+func (app *App) handleCloudKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	keys := app.keys
+
+	if app.state == StateAddingCloud {
+		switch {
+		case key.Matches(msg, keys.Back):
+			return app, func() tea.Msg { return BackMsg{} }
+		case key.Matches(msg, keys.Select) && app.cloudForm.lastField():
+			return app, app.submitCloudForm()
+		case key.Matches(msg, keys.Select):
+			return app, app.cloudForm.setFocus(app.cloudForm.focus + 1)
+		}
+		return app.updateSubComponents(msg)
+	}
+
+	if !app.cloudList.SettingFilter() {
+		switch {
+		case key.Matches(msg, keys.AddCloud):
+			return app, app.showCloudForm()
+		case key.Matches(msg, keys.Back) && app.cloudList.FilterState() == list.Unfiltered:
+			return app, func() tea.Msg { return BackMsg{} }
+		}
+	}
+	return app.updateSubComponents(msg)
+}
+
+// cloudListView renders the clouds screen
+func (app *App) cloudListView() string {
+	return lipgloss.JoinHorizontal(lipgloss.Top, "  ", app.cloudList.View())
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// typeText sends text to the app one key press at a time
+func typeText(app *App, text string) {
+	for _, r := range text {
+		app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+// runCmd runs cmd and any commands it batches, feeding the cloud and back
+// messages to the app
+func runCmd(app *App, cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	switch msg := cmd().(type) {
+	case tea.BatchMsg:
+		for _, cmd := range msg {
+			runCmd(app, cmd)
+		}
+	case CloudsLoadedMsg, CloudAddedMsg, BackMsg:
+		_, next := app.Update(msg)
+		runCmd(app, next)
+	}
+}
+
+func TestApp_Clouds(t *testing.T) {
+	metadata := filepath.Join(t.TempDir(), "endpoints.json")
+	if err := os.WriteFile(metadata, []byte(azureStackMetadata), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := NewFakeProvider(cloudTestSubscriptions)
+	app := NewApp(provider)
+	app.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	app.Update(app.loadSubscriptions())
+	app.cloudList.StatusMessageLifetime = time.Millisecond
+
+	_, cmd := app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyClouds)})
+	runCmd(app, cmd)
+	if app.state != StateManagingClouds {
+		t.Fatalf("Expected StateManagingClouds, got %v", app.state)
+	}
+	if item := app.cloudList.SelectedItem().(cloudItem); item.Name != AzurePublicCloud {
+		t.Errorf("Expected the active cloud highlighted, got %s", item.Name)
+	}
+	if view := app.View(); !strings.Contains(view, ActiveCloudMarker+AzurePublicCloud) || !strings.Contains(view, "AzureUSGovernment") {
+		t.Errorf("Expected the clouds listed, got:\n%s", view)
+	}
+
+	// Open the form and fill it in; bound keys like "a" are typed
+	app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyAddCloud)})
+	if app.state != StateAddingCloud {
+		t.Fatalf("Expected StateAddingCloud, got %v", app.state)
+	}
+	typeText(app, "AzureStackQa")
+	app.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	typeText(app, metadata)
+	app.Update(tea.KeyMsg{Type: tea.KeyTab})
+	typeText(app, "https://management.local.azurestack.external")
+	app.Update(tea.KeyMsg{Type: tea.KeyTab})
+	typeText(app, "2020-09-01-hybrid")
+
+	_, cmd = app.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	runCmd(app, cmd)
+	if app.state != StateManagingClouds {
+		t.Fatalf("Expected the clouds after registering, got %v with %v", app.state, app.cloudForm.err)
+	}
+	if len(provider.CustomClouds) != 1 {
+		t.Fatalf("Expected a registered cloud, got %+v", provider.CustomClouds)
+	}
+	if cloud := provider.CustomClouds[0]; cloud.Name != "AzureStackQa" || cloud.Profile != "2020-09-01-hybrid" ||
+		cloud.StorageSuffix != "local.azurestack.external" {
+		t.Errorf("Unexpected cloud %+v", cloud)
+	}
+	if view := app.View(); !strings.Contains(view, "AzureStackQa") {
+		t.Errorf("Expected the new cloud listed, got:\n%s", view)
+	}
+
+	// Esc goes back to the subscriptions
+	_, cmd = app.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	runCmd(app, cmd)
+	if app.state != StateSelectingSubscription {
+		t.Errorf("Expected StateSelectingSubscription, got %v", app.state)
+	}
+}
+
+func TestApp_CloudForm_Errors(t *testing.T) {
+	app := NewApp(NewFakeProvider(cloudTestSubscriptions))
+	app.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	app.Update(app.loadSubscriptions())
+	app.state = StateManagingClouds
+	app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyAddCloud)})
+
+	// Enter on the last field submits the empty form
+	app.Update(tea.KeyMsg{Type: tea.KeyShiftTab})
+	app.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if app.state != StateAddingCloud || app.cloudForm.err == nil {
+		t.Fatalf("Expected an error in the form, got %v", app.state)
+	}
+	if view := app.View(); !strings.Contains(view, "enter a name") {
+		t.Errorf("Expected the error shown, got:\n%s", view)
+	}
+
+	// Registering a built-in cloud again is refused
+	app.cloudForm = NewCloudForm(app.theme)
+	metadata := filepath.Join(t.TempDir(), "endpoints.json")
+	if err := os.WriteFile(metadata, []byte(armMetadataList), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	app.cloudForm.inputs[cloudFieldName].SetValue(AzurePublicCloud)
+	app.cloudForm.inputs[cloudFieldMetadata].SetValue(metadata)
+	runCmd(app, app.submitCloudForm())
+	if app.state != StateAddingCloud || app.cloudForm.err == nil || !strings.Contains(app.cloudForm.err.Error(), "already registered") {
+		t.Errorf("Expected ErrCloudExists in the form, got %v", app.cloudForm.err)
+	}
+
+	// Esc cancels the form
+	_, cmd := app.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	runCmd(app, cmd)
+	if app.state != StateManagingClouds || app.cloudForm != nil {
+		t.Errorf("Expected the clouds after cancelling, got %v", app.state)
+	}
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Azure cloud configuration
+const (
+	// AzurePublicCloud is the cloud used when none is configured
+	AzurePublicCloud = "AzureCloud"
+
+	// AzureConfigFileName is the Azure CLI's INI configuration, holding the
+	// active cloud in its [cloud] section
+	AzureConfigFileName = "config"
+	azureCloudSection   = "cloud"
+	azureCloudNameKey   = "name"
+
+	// azureCloudSubscriptionKey holds a cloud's default subscription in its
+	// clouds.config section
+	azureCloudSubscriptionKey = "subscription"
+
+	// CloudBadge is shown before the cloud of subscriptions outside AzureCloud
+	CloudBadge = "☁ "
+
+	// CloudChangedMessage reports a cloud switch on the result page
+	CloudChangedMessage = "Azure cloud changed from %s to %s"
+)
+
+// CloudSwitcher is implemented by providers that can switch the active Azure
+// cloud, like 'az cloud set'
+type CloudSwitcher interface {
+	// ActiveCloud returns the name of the active cloud
+	ActiveCloud() (string, error)
+	// SetCloud makes the named cloud active
+	SetCloud(name string) error
+}
+
+// SwitchResult describes the steps taken to switch to a subscription
+type SwitchResult struct {
+	// Changed reports whether the default subscription was changed
+	Changed bool
+	// FromCloud is the cloud switched away from, empty if it was kept
+	FromCloud string
+}
+
+// switchCloud makes the subscription's cloud active when the provider has
+// clouds and another one is active, returning the cloud switched away from
+func switchCloud(provider SubscriptionProvider, subscription Subscription) (string, error) {
+	switcher, ok := providerAs[CloudSwitcher](provider)
+	if !ok || subscription.CloudName == "" {
+		return "", nil
+	}
+
+	active, err := switcher.ActiveCloud()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the active cloud: %w", err)
+	}
+	if strings.EqualFold(active, subscription.CloudName) {
+		return "", nil
+	}
+
+	if err := switcher.SetCloud(subscription.CloudName); err != nil {
+		return "", fmt.Errorf("failed to change cloud to %s: %w", subscription.CloudName, err)
+	}
+	return active, nil
+}
+
+// activeCloud returns the provider's active cloud, or an empty string when
+// it has no clouds or the active one cannot be read
+func activeCloud(provider SubscriptionProvider) string {
+	switcher, ok := providerAs[CloudSwitcher](provider)
+	if !ok {
+		return ""
+	}
+	cloud, err := switcher.ActiveCloud()
+	if err != nil {
+		return ""
+	}
+	return cloud
+}
+
+// findActiveSubscription returns the index of the default subscription in
+// cloud, as each cloud keeps its own default, or -1 if there is none.
+// Subscriptions without a cloud match any, and an empty cloud matches all.
+func findActiveSubscription(subscriptions []Subscription, cloud string) int {
+	return slices.IndexFunc(subscriptions, func(s Subscription) bool {
+		return s.IsDefault && (cloud == "" || s.CloudName == "" || strings.EqualFold(s.CloudName, cloud))
+	})
+}
+
+// cloudBadge returns the badge shown for a subscription outside AzureCloud
+func cloudBadge(subscription Subscription) string {
+	if subscription.CloudName == "" || subscription.CloudName == AzurePublicCloud {
+		return ""
+	}
+	return CloudBadge + subscription.CloudName
+}
+
+// ActiveCloud implements CloudSwitcher. It reads the Azure CLI configuration
+// rather than running 'az cloud show', which would double the cost of every
+// load and switch.
+func (p *AzureCLIProvider) ActiveCloud() (string, error) {
+	dir, err := azureConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return readActiveCloud(filepath.Join(dir, AzureConfigFileName))
+}
+
+// SetCloud implements CloudSwitcher
+func (p *AzureCLIProvider) SetCloud(name string) error {
+	if !isAzureCLIAvailable(p.Command) {
+		return ErrAzureCLINotFound
+	}
+
+	if _, err := exec.Command(p.Command, "cloud", "set", "--name", name).Output(); err != nil {
+		return azureCLIError(err)
+	}
+
+	return nil
+}
+
+// configPath returns the Azure CLI configuration next to the profile
+func (p *NativeProvider) configPath() string {
+	return filepath.Join(filepath.Dir(p.path), AzureConfigFileName)
+}
+
+// ActiveCloud implements CloudSwitcher, reading the Azure CLI configuration
+func (p *NativeProvider) ActiveCloud() (string, error) {
+	return readActiveCloud(p.configPath())
+}
+
+// readActiveCloud returns the cloud named in the [cloud] section of the Azure
+// CLI configuration at path, or AzureCloud when none is
+func readActiveCloud(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return AzurePublicCloud, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	for _, section := range parseINI(data) {
+		if section.name == azureCloudSection && section.values[azureCloudNameKey] != "" {
+			return section.values[azureCloudNameKey], nil
+		}
+	}
+
+	return AzurePublicCloud, nil
+}
+
+// SetCloud implements CloudSwitcher. Like 'az cloud set', it sets the name
+// in the [cloud] section of the Azure CLI configuration, preserving the rest.
+func (p *NativeProvider) SetCloud(name string) error {
+	return updateINIFile(p.configPath(), azureCloudSection, azureCloudNameKey, name)
+}
+
+// updateINIFile sets key in section of the INI file at path, creating the
+// file and the section as needed and preserving the rest
+func updateINIFile(path, section, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	return writeFileAtomic(path, setINIValue(data, section, key, value))
+}
+
+// setINIValue returns the INI data with key set in section
+func setINIValue(data []byte, section, key, value string) []byte {
+	setting := key + " = " + value
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	}
+
+	var current string
+	header, done := -1, false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if name, ok := iniSection(trimmed); ok {
+			current = name
+			if current == section {
+				header = i
+			}
+			continue
+		}
+		if k, _, ok := iniValue(trimmed); ok && current == section && k == key {
+			lines[i] = setting
+			done = true
+			break
+		}
+	}
+
+	switch {
+	case done:
+	case header >= 0:
+		lines = append(lines[:header+1], append([]string{setting}, lines[header+1:]...)...)
+	default:
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+section+"]", setting)
+	}
+
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// iniTable is a section of an INI file
+type iniTable struct {
+	name   string
+	values map[string]string
+}
+
+// parseINI reads the sections of an INI file, as written by the Azure CLI,
+// in file order
+func parseINI(data []byte) []iniTable {
+	var tables []iniTable
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if name, ok := iniSection(line); ok {
+			tables = append(tables, iniTable{name: name, values: map[string]string{}})
+			continue
+		}
+		if key, value, ok := iniValue(line); ok && len(tables) > 0 {
+			tables[len(tables)-1].values[key] = value
+		}
+	}
+	return tables
+}
+
+// iniSection returns the name of an INI section header
+func iniSection(line string) (string, bool) {
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return "", false
+	}
+	return strings.TrimSpace(line[1 : len(line)-1]), true
+}
+
+// iniValue splits an INI "key = value" line, skipping comments
+func iniValue(line string) (string, string, bool) {
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+		return "", "", false
+	}
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), true
+}
+
+// Cloud registry configuration
+const (
+	// AzureCloudsFileName is the Azure CLI's INI file of registered clouds
+	AzureCloudsFileName = "clouds.config"
+
+	// DefaultCloudProfile is the API profile of newly registered clouds
+	DefaultCloudProfile = "latest"
+)
+
+// ErrCloudExists is returned when registering a cloud under a taken name
+var ErrCloudExists = errors.New("a cloud with this name is already registered")
+
+// Cloud is an Azure cloud registered with the Azure CLI
+type Cloud struct {
+	Name     string `json:"name"`
+	IsActive bool   `json:"isActive"`
+	Profile  string `json:"profile"`
+
+	ResourceManager                string `json:"resourceManager"`
+	ActiveDirectory                string `json:"activeDirectory"`
+	ActiveDirectoryResourceID      string `json:"activeDirectoryResourceId"`
+	ActiveDirectoryGraphResourceID string `json:"activeDirectoryGraphResourceId"`
+	Gallery                        string `json:"gallery"`
+	StorageSuffix                  string `json:"storageEndpoint"`
+	KeyVaultDNSSuffix              string `json:"keyvaultDns"`
+}
+
+// cloudSetting is an endpoint or suffix of a cloud, with its clouds.config
+// key and 'az cloud register' flag
+type cloudSetting struct {
+	key, flag string
+	value     *string
+}
+
+// cloudSettings returns the cloud's endpoints and suffixes
+func (c *Cloud) cloudSettings() []cloudSetting {
+	return []cloudSetting{
+		{"endpoint_resource_manager", "--endpoint-resource-manager", &c.ResourceManager},
+		{"endpoint_active_directory", "--endpoint-active-directory", &c.ActiveDirectory},
+		{"endpoint_active_directory_resource_id", "--endpoint-active-directory-resource-id", &c.ActiveDirectoryResourceID},
+		{"endpoint_active_directory_graph_resource_id", "--endpoint-active-directory-graph-resource-id", &c.ActiveDirectoryGraphResourceID},
+		{"endpoint_gallery", "--endpoint-gallery", &c.Gallery},
+		{"suffix_storage_endpoint", "--suffix-storage-endpoint", &c.StorageSuffix},
+		{"suffix_keyvault_dns", "--suffix-keyvault-dns", &c.KeyVaultDNSSuffix},
+	}
+}
+
+// builtinClouds are the clouds known to the Azure CLI without registration
+var builtinClouds = []Cloud{
+	{Name: AzurePublicCloud, Profile: DefaultCloudProfile, ResourceManager: "https://management.azure.com/"},
+	{Name: "AzureChinaCloud", Profile: DefaultCloudProfile, ResourceManager: "https://management.chinacloudapi.cn"},
+	{Name: "AzureUSGovernment", Profile: DefaultCloudProfile, ResourceManager: "https://management.usgovcloudapi.net/"},
+}
+
+// CloudRegistry is implemented by providers that can list and register
+// clouds, like 'az cloud list' and 'az cloud register'
+type CloudRegistry interface {
+	// Clouds returns the built-in and registered clouds
+	Clouds() ([]Cloud, error)
+	// RegisterCloud registers a custom cloud
+	RegisterCloud(cloud Cloud) error
+}
+
+// Clouds implements CloudRegistry
+func (p *AzureCLIProvider) Clouds() ([]Cloud, error) {
+	if !isAzureCLIAvailable(p.Command) {
+		return nil, ErrAzureCLINotFound
+	}
+
+	data, err := exec.Command(p.Command, "cloud", "list", "--output", "json", "--query", AzureCloudListQuery).Output()
+	if err != nil {
+		return nil, azureCLIError(err)
+	}
+
+	var clouds []Cloud
+	if err := json.Unmarshal(data, &clouds); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return clouds, nil
+}
+
+// AzureCloudListQuery selects the fields of Cloud from 'az cloud list'
+const AzureCloudListQuery = "[].{name:name,isActive:isActive,profile:profile," +
+	"resourceManager:endpoints.resourceManager,activeDirectory:endpoints.activeDirectory," +
+	"activeDirectoryResourceId:endpoints.activeDirectoryResourceId," +
+	"activeDirectoryGraphResourceId:endpoints.activeDirectoryGraphResourceId,gallery:endpoints.gallery," +
+	"storageEndpoint:suffixes.storageEndpoint,keyvaultDns:suffixes.keyvaultDns}"
+
+// RegisterCloud implements CloudRegistry
+func (p *AzureCLIProvider) RegisterCloud(cloud Cloud) error {
+	if !isAzureCLIAvailable(p.Command) {
+		return ErrAzureCLINotFound
+	}
+
+	if _, err := exec.Command(p.Command, azureCloudRegisterArgs(cloud)...).Output(); err != nil {
+		return azureCLIError(err)
+	}
+	return nil
+}
+
+// azureCloudRegisterArgs returns the 'az cloud register' arguments for cloud
+func azureCloudRegisterArgs(cloud Cloud) []string {
+	args := []string{"cloud", "register", "--name", cloud.Name, "--profile", cmp.Or(cloud.Profile, DefaultCloudProfile)}
+	for _, setting := range cloud.cloudSettings() {
+		if *setting.value != "" {
+			args = append(args, setting.flag, *setting.value)
+		}
+	}
+	return args
+}
+
+// cloudsPath returns the registered clouds file next to the profile
+func (p *NativeProvider) cloudsPath() string {
+	return filepath.Join(filepath.Dir(p.path), AzureCloudsFileName)
+}
+
+// Clouds implements CloudRegistry, reading the built-in clouds and those in
+// clouds.config. The file may also hold settings for built-in clouds.
+func (p *NativeProvider) Clouds() ([]Cloud, error) {
+	data, err := os.ReadFile(p.cloudsPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", p.cloudsPath(), err)
+	}
+
+	clouds := slices.Clone(builtinClouds)
+	for _, section := range parseINI(data) {
+		index := slices.IndexFunc(clouds, func(cloud Cloud) bool { return cloud.Name == section.name })
+		if index < 0 {
+			index = len(clouds)
+			clouds = append(clouds, Cloud{Name: section.name, Profile: DefaultCloudProfile})
+		}
+		cloud := &clouds[index]
+		cloud.Profile = cmp.Or(section.values["profile"], cloud.Profile)
+		for _, setting := range cloud.cloudSettings() {
+			*setting.value = cmp.Or(section.values[setting.key], *setting.value)
+		}
+	}
+
+	active, err := p.ActiveCloud()
+	if err != nil {
+		return nil, err
+	}
+	for i := range clouds {
+		clouds[i].IsActive = clouds[i].Name == active
+	}
+	return clouds, nil
+}
+
+// RegisterCloud implements CloudRegistry. Like 'az cloud register', it adds
+// the cloud's endpoints to clouds.config.
+func (p *NativeProvider) RegisterCloud(cloud Cloud) error {
+	clouds, err := p.Clouds()
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(clouds, func(c Cloud) bool { return strings.EqualFold(c.Name, cloud.Name) }) {
+		return fmt.Errorf("%w: %s", ErrCloudExists, cloud.Name)
+	}
+
+	path := p.cloudsPath()
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var out strings.Builder
+	if content := strings.TrimRight(string(data), "\n"); content != "" {
+		out.WriteString(content + "\n\n")
+	}
+	fmt.Fprintf(&out, "[%s]\nprofile = %s\n", cloud.Name, cmp.Or(cloud.Profile, DefaultCloudProfile))
+	for _, setting := range cloud.cloudSettings() {
+		if *setting.value != "" {
+			fmt.Fprintf(&out, "%s = %s\n", setting.key, *setting.value)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	return writeFileAtomic(path, []byte(out.String()))
+}
+
+// armMetadata is a cloud as described by an ARM metadata endpoint. The
+// 2019-05-01 format names every endpoint; the 2015-01-01 format, still
+// served by Azure Stack Hub, leaves out the resource manager and suffixes.
+type armMetadata struct {
+	Name            string `json:"name"`
+	ResourceManager string `json:"resourceManager"`
+	Gallery         string `json:"gallery"`
+	Graph           string `json:"graph"`
+	GraphAudience   string `json:"graphAudience"`
+	Authentication  struct {
+		LoginEndpoint string   `json:"loginEndpoint"`
+		Audiences     []string `json:"audiences"`
+	} `json:"authentication"`
+	Suffixes struct {
+		Storage     string `json:"storage"`
+		KeyVaultDNS string `json:"keyVaultDns"`
+	} `json:"suffixes"`
+
+	// 2015-01-01 names
+	GalleryEndpoint string `json:"galleryEndpoint"`
+	GraphEndpoint   string `json:"graphEndpoint"`
+}
+
+// parseCloudMetadata builds a cloud from the response of an ARM metadata
+// endpoint. A list of clouds must contain name. resourceManager is used when
+// the metadata does not include it, and suffixes missing from the metadata
+// are derived from it the way Azure Stack Hub names them.
+func parseCloudMetadata(data []byte, name, resourceManager string) (Cloud, error) {
+	var metadata armMetadata
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("[")) {
+		var list []armMetadata
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return Cloud{}, fmt.Errorf("failed to parse metadata: %w", err)
+		}
+		index := slices.IndexFunc(list, func(m armMetadata) bool { return strings.EqualFold(m.Name, name) })
+		if index < 0 && len(list) == 1 {
+			index = 0
+		}
+		if index < 0 {
+			return Cloud{}, fmt.Errorf("metadata does not describe a cloud named %q", name)
+		}
+		metadata = list[index]
+	} else if err := json.Unmarshal(trimmed, &metadata); err != nil {
+		return Cloud{}, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	cloud := Cloud{
+		Name:                           name,
+		Profile:                        DefaultCloudProfile,
+		ResourceManager:                cmp.Or(metadata.ResourceManager, resourceManager),
+		ActiveDirectory:                metadata.Authentication.LoginEndpoint,
+		ActiveDirectoryGraphResourceID: cmp.Or(metadata.GraphAudience, metadata.Graph, metadata.GraphEndpoint),
+		Gallery:                        cmp.Or(metadata.Gallery, metadata.GalleryEndpoint),
+		StorageSuffix:                  metadata.Suffixes.Storage,
+		KeyVaultDNSSuffix:              metadata.Suffixes.KeyVaultDNS,
+	}
+	if len(metadata.Authentication.Audiences) > 0 {
+		cloud.ActiveDirectoryResourceID = metadata.Authentication.Audiences[0]
+	}
+
+	if cloud.ResourceManager == "" {
+		return Cloud{}, errors.New("metadata has no resource manager endpoint, enter it in the form")
+	}
+	if cloud.ActiveDirectory == "" {
+		return Cloud{}, errors.New("metadata has no authentication login endpoint")
+	}
+
+	// Azure Stack Hub serves management.<region>.<fqdn>, storage from
+	// <region>.<fqdn> and key vaults from vault.<region>.<fqdn>
+	if host := resourceManagerHost(cloud.ResourceManager); host != "" {
+		if cloud.StorageSuffix == "" {
+			cloud.StorageSuffix = host
+		}
+		if cloud.KeyVaultDNSSuffix == "" {
+			cloud.KeyVaultDNSSuffix = ".vault." + host
+		}
+	}
+
+	return cloud, nil
+}
+
+// resourceManagerHost returns the resource manager host without its
+// "management." label, or "" if it has none
+func resourceManagerHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	host, ok := strings.CutPrefix(u.Hostname(), "management.")
+	if !ok {
+		return ""
+	}
+	return host
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var cloudTestSubscriptions = []Subscription{
+	{ID: "sub-1", Name: "Commercial", CloudName: AzurePublicCloud, TenantID: "t-1", IsDefault: true},
+	{ID: "sub-2", Name: "Government", CloudName: "AzureUSGovernment", TenantID: "t-2"},
+}
+
+func TestSubscription_CloudBadge(t *testing.T) {
+	if badge := cloudBadge(cloudTestSubscriptions[0]); badge != "" {
+		t.Errorf("Expected no badge for AzureCloud, got %q", badge)
+	}
+	if badge := cloudBadge(cloudTestSubscriptions[1]); badge != CloudBadge+"AzureUSGovernment" {
+		t.Errorf("Expected a cloud badge, got %q", badge)
+	}
+	if value := cloudTestSubscriptions[1].FilterValue(); !strings.Contains(value, "AzureUSGovernment") {
+		t.Errorf("Expected the cloud to be filterable, got %q", value)
+	}
+
+	app := NewApp(NewFakeProvider(cloudTestSubscriptions))
+	app.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	app.Update(app.loadSubscriptions())
+	if view := app.View(); !strings.Contains(view, CloudBadge+"AzureUSGovernment") {
+		t.Errorf("Expected the badge in the list, got:\n%s", view)
+	}
+}
+
+func TestSwitchSubscription_Cloud(t *testing.T) {
+	provider := NewFakeProvider(cloudTestSubscriptions)
+
+	result, err := switchSubscription(provider, cloudTestSubscriptions[1], "sub-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !result.Changed || result.FromCloud != AzurePublicCloud || provider.Cloud != "AzureUSGovernment" {
+		t.Errorf("Expected a cloud and subscription switch, got %+v in %s", result, provider.Cloud)
+	}
+
+	var out bytes.Buffer
+	printSwitchResult(&out, result, cloudTestSubscriptions[1])
+	if !strings.HasPrefix(out.String(), "Azure cloud changed from AzureCloud to AzureUSGovernment\n"+SuccessMessage) {
+		t.Errorf("Expected both steps reported, got %q", out.String())
+	}
+
+	// The same cloud is not set again
+	result, err = switchSubscription(provider, cloudTestSubscriptions[1], "sub-2")
+	if err != nil || result.Changed || result.FromCloud != "" {
+		t.Errorf("Expected no change, got %+v, %v", result, err)
+	}
+}
+
+func TestApp_ChangeSubscription_Cloud(t *testing.T) {
+	app := NewApp(NewFakeProvider(cloudTestSubscriptions))
+	app.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	app.Update(app.loadSubscriptions())
+
+	app.Update(app.changeSubscription(cloudTestSubscriptions[1])())
+	if app.state != StateShowingResult {
+		t.Fatalf("Expected StateShowingResult, got %v", app.state)
+	}
+	view := app.View()
+	if !strings.Contains(view, "Azure cloud changed from AzureCloud to AzureUSGovernment") || !strings.Contains(view, SuccessMessage) {
+		t.Errorf("Expected both steps on the result page, got:\n%s", view)
+	}
+}
+
+func TestNativeProvider_Cloud(t *testing.T) {
+	dir := t.TempDir()
+	provider := NewNativeProvider(filepath.Join(dir, AzureProfileFileName))
+
+	if cloud, err := provider.ActiveCloud(); err != nil || cloud != AzurePublicCloud {
+		t.Errorf("Expected AzureCloud without a configuration, got %q, %v", cloud, err)
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{name: "missing", config: "", want: "[cloud]\nname = AzureChinaCloud\n"},
+		{
+			name:   "other sections",
+			config: "[core]\noutput = json\n",
+			want:   "[core]\noutput = json\n\n[cloud]\nname = AzureChinaCloud\n",
+		},
+		{
+			name:   "no name",
+			config: "[cloud]\n# comment\n[core]\nname = keep\n",
+			want:   "[cloud]\nname = AzureChinaCloud\n# comment\n[core]\nname = keep\n",
+		},
+		{
+			name:   "replaced",
+			config: "[core]\nname = keep\n\n[cloud]\nname = AzureCloud\n\n[defaults]\nlocation = westeurope\n",
+			want:   "[core]\nname = keep\n\n[cloud]\nname = AzureChinaCloud\n\n[defaults]\nlocation = westeurope\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := provider.configPath()
+			os.Remove(path)
+			if tt.config != "" {
+				if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := provider.SetCloud("AzureChinaCloud"); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			data, _ := os.ReadFile(path)
+			if string(data) != tt.want {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tt.want, data)
+			}
+			if cloud, err := provider.ActiveCloud(); err != nil || cloud != "AzureChinaCloud" {
+				t.Errorf("Expected AzureChinaCloud, got %q, %v", cloud, err)
+			}
+		})
+	}
+}
+
+func TestAzureCLIProvider_ActiveCloud(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(EnvAzureConfigDir, dir)
+	// The cloud is read from the configuration, without running az
+	provider := NewAzureCLIProvider("asubselect-missing-az")
+
+	if cloud, err := provider.ActiveCloud(); err != nil || cloud != AzurePublicCloud {
+		t.Errorf("Expected AzureCloud without a configuration, got %q, %v", cloud, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, AzureConfigFileName), []byte("[cloud]\nname = AzureUSGovernment\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if cloud, err := provider.ActiveCloud(); err != nil || cloud != "AzureUSGovernment" {
+		t.Errorf("Expected AzureUSGovernment, got %q, %v", cloud, err)
+	}
+}
+
+func TestNativeProvider_SetDefault_PerCloud(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AzureProfileFileName)
+	profile := `{"subscriptions": [
+		{"id": "sub-1", "isDefault": true, "environmentName": "AzureCloud"},
+		{"id": "sub-2", "isDefault": true, "environmentName": "AzureUSGovernment"},
+		{"id": "sub-3", "isDefault": false, "environmentName": "AzureUSGovernment"}
+	]}`
+	if err := os.WriteFile(path, []byte(profile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	provider := NewNativeProvider(path)
+
+	if err := provider.SetDefault(Subscription{ID: "sub-3"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	subs, err := provider.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var defaults []string
+	for _, sub := range subs {
+		if sub.IsDefault {
+			defaults = append(defaults, sub.ID+"@"+sub.CloudName)
+		}
+	}
+	if got := strings.Join(defaults, ","); got != "sub-1@AzureCloud,sub-3@AzureUSGovernment" {
+		t.Errorf("Expected one default per cloud, got %s", got)
+	}
+}
+
+func TestNativeProvider_Current_PerCloud(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AzureProfileFileName)
+	profile := `{"subscriptions": [
+		{"id": "sub-1", "name": "Commercial", "isDefault": true, "environmentName": "AzureCloud"},
+		{"id": "sub-2", "name": "Government", "isDefault": false, "environmentName": "AzureUSGovernment"}
+	]}`
+	if err := os.WriteFile(path, []byte(profile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	provider := NewNativeProvider(path)
+
+	subs, err := provider.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := switchSubscription(provider, subs[1], activeSubscriptionID(subs, activeCloud(provider))); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// sub-1 stays the default of AzureCloud, but AzureUSGovernment is active
+	if current, err := provider.Current(); err != nil || current.ID != "sub-2" {
+		t.Errorf("Expected sub-2 current, got %q, %v", current.ID, err)
+	}
+	subs, _ = provider.List()
+	if id := activeSubscriptionID(subs, activeCloud(provider)); id != "sub-2" {
+		t.Errorf("Expected sub-2 active, got %q", id)
+	}
+	if normalized := normalizeDefault(subs, activeCloud(provider)); normalized[0].IsDefault || !normalized[1].IsDefault {
+		t.Errorf("Expected only sub-2 marked default, got %+v", normalized)
+	}
+
+	app := NewApp(provider)
+	app.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	app.Update(app.loadSubscriptions())
+	if app.selectedID != "sub-2" {
+		t.Errorf("Expected sub-2 preselected, got %q", app.selectedID)
+	}
+
+	// Switching back only needs the cloud changed
+	if result, err := switchSubscription(provider, subs[0], "sub-2"); err != nil || result.FromCloud != "AzureUSGovernment" {
+		t.Errorf("Expected a switch back to AzureCloud, got %+v, %v", result, err)
+	}
+	if current, err := provider.Current(); err != nil || current.ID != "sub-1" {
+		t.Errorf("Expected sub-1 current, got %q, %v", current.ID, err)
+	}
+}
+
+// azureStackMetadata is a 2015-01-01 metadata response from Azure Stack Hub
+const azureStackMetadata = `{
+	"galleryEndpoint": "https://providers.local.azurestack.external:30016/",
+	"graphEndpoint": "https://graph.windows.net/",
+	"portalEndpoint": "https://portal.local.azurestack.external/",
+	"authentication": {
+		"loginEndpoint": "https://login.microsoftonline.com/",
+		"audiences": ["https://management.contoso.onmicrosoft.com/51234567-89ab-cdef-0123-456789abcdef"]
+	}
+}`
+
+// armMetadataList is a 2019-05-01 metadata response listing clouds
+const armMetadataList = `[
+	{
+		"name": "AzureCloud",
+		"resourceManager": "https://management.azure.com/",
+		"authentication": {"loginEndpoint": "https://login.microsoftonline.com", "audiences": ["https://management.core.windows.net/"]},
+		"suffixes": {"storage": "core.windows.net", "keyVaultDns": "vault.azure.net"}
+	},
+	{
+		"name": "Contoso",
+		"resourceManager": "https://management.contoso.example/",
+		"graphAudience": "https://graph.contoso.example/",
+		"gallery": "https://gallery.contoso.example/",
+		"authentication": {"loginEndpoint": "https://login.contoso.example", "audiences": ["https://management.contoso.example/"]},
+		"suffixes": {"storage": "storage.contoso.example", "keyVaultDns": ".vault.contoso.example"}
+	}
+]`
+
+func TestParseCloudMetadata(t *testing.T) {
+	tests := []struct {
+		name            string
+		data            string
+		cloud           string
+		resourceManager string
+		want            Cloud
+		wantErr         string
+	}{
+		{
+			name:            "azure stack",
+			data:            azureStackMetadata,
+			cloud:           "AzureStackUser",
+			resourceManager: "https://management.local.azurestack.external",
+			want: Cloud{
+				Name:                           "AzureStackUser",
+				Profile:                        DefaultCloudProfile,
+				ResourceManager:                "https://management.local.azurestack.external",
+				ActiveDirectory:                "https://login.microsoftonline.com/",
+				ActiveDirectoryResourceID:      "https://management.contoso.onmicrosoft.com/51234567-89ab-cdef-0123-456789abcdef",
+				ActiveDirectoryGraphResourceID: "https://graph.windows.net/",
+				Gallery:                        "https://providers.local.azurestack.external:30016/",
+				StorageSuffix:                  "local.azurestack.external",
+				KeyVaultDNSSuffix:              ".vault.local.azurestack.external",
+			},
+		},
+		{
+			name:  "list",
+			data:  armMetadataList,
+			cloud: "contoso",
+			want: Cloud{
+				Name:                           "contoso",
+				Profile:                        DefaultCloudProfile,
+				ResourceManager:                "https://management.contoso.example/",
+				ActiveDirectory:                "https://login.contoso.example",
+				ActiveDirectoryResourceID:      "https://management.contoso.example/",
+				ActiveDirectoryGraphResourceID: "https://graph.contoso.example/",
+				Gallery:                        "https://gallery.contoso.example/",
+				StorageSuffix:                  "storage.contoso.example",
+				KeyVaultDNSSuffix:              ".vault.contoso.example",
+			},
+		},
+		{name: "no resource manager", data: azureStackMetadata, cloud: "AzureStackUser", wantErr: "no resource manager"},
+		{name: "unknown cloud", data: armMetadataList, cloud: "Fabrikam", wantErr: `named "Fabrikam"`},
+		{name: "invalid", data: "<html>", cloud: "x", wantErr: "failed to parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloud, err := parseCloudMetadata([]byte(tt.data), tt.cloud, tt.resourceManager)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if cloud != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, cloud)
+			}
+		})
+	}
+}
+
+func TestNativeProvider_RegisterCloud(t *testing.T) {
+	dir := t.TempDir()
+	provider := NewNativeProvider(filepath.Join(dir, AzureProfileFileName))
+
+	// Built-in clouds may have settings in clouds.config too
+	existing := "[AzureCloud]\nsubscription = sub-1\n"
+	if err := os.WriteFile(provider.cloudsPath(), []byte(existing), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.SetCloud("AzureUSGovernment"); err != nil {
+		t.Fatal(err)
+	}
+
+	cloud, err := parseCloudMetadata([]byte(azureStackMetadata), "AzureStackUser", "https://management.local.azurestack.external")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cloud.Profile = "2020-09-01-hybrid"
+	if err := provider.RegisterCloud(cloud); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := provider.RegisterCloud(cloud); !errors.Is(err, ErrCloudExists) {
+		t.Errorf("Expected ErrCloudExists registering twice, got %v", err)
+	}
+
+	data, _ := os.ReadFile(provider.cloudsPath())
+	if !strings.HasPrefix(string(data), existing+"\n[AzureStackUser]\nprofile = 2020-09-01-hybrid\n") ||
+		!strings.Contains(string(data), "endpoint_resource_manager = https://management.local.azurestack.external\n") {
+		t.Errorf("Unexpected clouds.config:\n%s", data)
+	}
+
+	clouds, err := provider.Clouds()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var names []string
+	for _, c := range clouds {
+		if c.IsActive {
+			names = append(names, "*"+c.Name)
+		} else {
+			names = append(names, c.Name)
+		}
+	}
+	if got := strings.Join(names, ","); got != "AzureCloud,AzureChinaCloud,*AzureUSGovernment,AzureStackUser" {
+		t.Errorf("Unexpected clouds %s", got)
+	}
+	if clouds[3] != cloud {
+		t.Errorf("Expected the registered cloud read back, got %+v", clouds[3])
+	}
+}
+
+func TestAzureCloudRegisterArgs(t *testing.T) {
+	args := azureCloudRegisterArgs(Cloud{Name: "Stack", ResourceManager: "https://management.example", StorageSuffix: "example"})
+	want := "cloud register --name Stack --profile latest --endpoint-resource-manager https://management.example --suffix-storage-endpoint example"
+	if got := strings.Join(args, " "); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestProviderAs_Alias(t *testing.T) {
+	config := DefaultConfig()
+	config.Aliases = map[string]SubscriptionAlias{"sub-1": {Alias: "one"}}
+	provider := config.withAliases(NewFakeProvider(cloudTestSubscriptions))
+
+	if _, ok := provider.(CloudSwitcher); ok {
+		t.Fatal("Expected the alias provider to wrap the fake provider")
+	}
+	if _, ok := providerAs[CloudSwitcher](provider); !ok {
+		t.Error("Expected the wrapped provider to switch clouds")
+	}
+	if _, ok := providerAs[CloudRegistry](NewAzureCLIProvider(AzureCommand)); !ok {
+		t.Error("Expected the Azure CLI provider to register clouds")
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// Subcommand names
+const (
+	CommandSet     = "set"
+	CommandList    = "list"
+	CommandCurrent = "current"
+)
+
+// Output formats accepted by 'asubselect list --output'
+const (
+	OutputJSON  = "json"
+	OutputTable = "table"
+	OutputTSV   = "tsv"
+	OutputNames = "names"
+)
+
+var outputFormats = []string{OutputJSON, OutputTable, OutputTSV, OutputNames}
+
+// Formats accepted by 'asubselect current --format'. Anything containing
+// "{{" is treated as a text/template executed against the Subscription.
+const (
+	FormatName       = "name"
+	FormatTenantName = "tenant/name"
+	FormatID         = "id"
+	FormatShortID    = "short"
+	FormatUser       = "user"
+	FormatAlias      = "alias"
+
+	shortIDLength = 8
+)
+
+// ErrUsage is returned when a subcommand is invoked with invalid arguments
+var ErrUsage = errors.New("invalid usage")
+
+// newCommandFlags creates a flag set for a subcommand that reports errors
+// instead of exiting
+func newCommandFlags(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(AppName+" "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
+
+// activeSubscriptionID returns the ID of the default subscription in the
+// active cloud, as preselected by the TUI, or an empty string if there is none
+func activeSubscriptionID(subscriptions []Subscription, cloud string) string {
+	if index := findActiveSubscription(subscriptions, cloud); index >= 0 {
+		return subscriptions[index].ID
+	}
+	return ""
+}
+
+// switchSubscription makes subscription the default unless it is already the
+// active one, first switching to its cloud if another one is active
+func switchSubscription(provider SubscriptionProvider, subscription Subscription, activeID string) (SwitchResult, error) {
+	fromCloud, err := switchCloud(provider, subscription)
+	if err != nil {
+		return SwitchResult{}, err
+	}
+	result := SwitchResult{FromCloud: fromCloud}
+
+	// The active ID is only known for the cloud that was active
+	if subscription.ID == activeID && fromCloud == "" {
+		return result, nil
+	}
+
+	if err := provider.SetDefault(subscription); err != nil {
+		return result, fmt.Errorf("failed to change subscription: %w", err)
+	}
+
+	result.Changed = true
+	return result, nil
+}
+
+// printSwitchResult reports the outcome of a subscription switch
+func printSwitchResult(out io.Writer, result SwitchResult, subscription Subscription) {
+	if result.FromCloud != "" {
+		fmt.Fprintf(out, CloudChangedMessage+"\n", result.FromCloud, subscription.CloudName)
+	}
+
+	message := NoChangeMessage
+	if result.Changed {
+		message = SuccessMessage
+	}
+
+	fmt.Fprintf(out, "%s\n%s\n", message, subscription.Title())
+}
+
+// runSet implements 'asubselect set <query>'. A unique match is switched to
+// immediately; several matches open the TUI listing just those.
+func runSet(config *Config, provider SubscriptionProvider, args []string, stdout, stderr io.Writer) error {
+	flags := newCommandFlags(CommandSet, stderr)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w: %s %s <query>", ErrUsage, AppName, CommandSet)
+	}
+
+	_, err := selectSubscription(config, provider, loadUserHistory(), flags.Arg(0), stdout)
+	return err
+}
+
+// selectSubscription switches to the subscription matching query. An empty
+// query or several matches open the TUI, listing just the matches. It reports
+// whether a subscription was chosen rather than the picker being quit.
+// Switches are recorded in history.
+func selectSubscription(config *Config, provider SubscriptionProvider, history *History, query string, stdout io.Writer) (bool, error) {
+	if query == "" {
+		app := newUserApp(config, provider)
+		app.history = history
+		return runPicker(app)
+	}
+
+	subscriptions, err := provider.List()
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch subscription data: %w", err)
+	}
+
+	subscription, matches, err := resolveSubscription(subscriptions, query)
+	if err != nil {
+		return false, err
+	}
+
+	if len(matches) > 1 {
+		app := newUserApp(config, provider)
+		app.history = history
+		// The list filter does not match IDs, so offer the matches directly
+		app.initialMatches = subscriptionIDs(matches)
+		return runPicker(app)
+	}
+
+	activeID := activeSubscriptionID(subscriptions, activeCloud(provider))
+	result, err := switchSubscription(provider, subscription, activeID)
+	if err != nil {
+		return false, err
+	}
+	if result.Changed {
+		if err := history.RecordSwitch(subscriptions, activeID, subscription, SourceCLI); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	printSwitchResult(stdout, result, subscription)
+	return true, nil
+}
+
+// runPicker runs the TUI and reports whether a subscription was chosen
+func runPicker(app *App) (bool, error) {
+	if err := runTUI(app); err != nil {
+		return false, err
+	}
+	return app.state == StateShowingResult, nil
+}
+
+// runList implements 'asubselect list'
+func runList(provider SubscriptionProvider, args []string, stdout, stderr io.Writer) error {
+	flags := newCommandFlags(CommandList, stderr)
+	output := flags.String("output", OutputTable, "output format: json, table, tsv or names")
+	tenant := flags.String("tenant", "", "only list subscriptions in this tenant")
+	user := flags.String("user", "", "only list subscriptions for this user")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("%w: %s %s [flags]", ErrUsage, AppName, CommandList)
+	}
+	if !slices.Contains(outputFormats, *output) {
+		return fmt.Errorf("%w: unknown output format %q", ErrUsage, *output)
+	}
+
+	subscriptions, err := provider.List()
+	if err != nil {
+		return fmt.Errorf("failed to fetch subscription data: %w", err)
+	}
+
+	subscriptions = filterSubscriptions(normalizeDefault(subscriptions, activeCloud(provider)), *tenant, *user)
+	return writeSubscriptions(stdout, *output, subscriptions)
+}
+
+// normalizeDefault marks only the subscription the TUI would preselect in
+// cloud as default, since az may report several and each cloud has its own
+func normalizeDefault(subscriptions []Subscription, cloud string) []Subscription {
+	defaultIndex := findActiveSubscription(subscriptions, cloud)
+	normalized := make([]Subscription, len(subscriptions))
+	for i, sub := range subscriptions {
+		sub.IsDefault = i == defaultIndex
+		normalized[i] = sub
+	}
+	return normalized
+}
+
+// filterSubscriptions keeps subscriptions matching the tenant and user, both
+// compared case-insensitively and ignored when empty
+func filterSubscriptions(subscriptions []Subscription, tenant, user string) []Subscription {
+	filtered := make([]Subscription, 0, len(subscriptions))
+	for _, sub := range subscriptions {
+		if tenant != "" && !strings.EqualFold(sub.TenantDisplayName, tenant) {
+			continue
+		}
+		if user != "" && !strings.EqualFold(sub.User.Name, user) {
+			continue
+		}
+		filtered = append(filtered, sub)
+	}
+	return filtered
+}
+
+// writeSubscriptions renders subscriptions in the given output format
+func writeSubscriptions(out io.Writer, format string, subscriptions []Subscription) error {
+	switch format {
+	case OutputJSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(subscriptions)
+	case OutputTable:
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, " \tNAME\tALIAS\tTENANT\tID\tUSER")
+		for _, sub := range subscriptions {
+			marker := " "
+			if sub.IsDefault {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", marker, sub.Name, sub.Alias, sub.TenantDisplayName, sub.ID, sub.User.Name)
+		}
+		return w.Flush()
+	case OutputTSV:
+		for _, sub := range subscriptions {
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%t\n", sub.ID, sub.Name, sub.TenantDisplayName, sub.User.Name, sub.IsDefault)
+		}
+		return nil
+	case OutputNames:
+		for _, sub := range subscriptions {
+			fmt.Fprintln(out, sub.Name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown output format %q", ErrUsage, format)
+	}
+}
+
+// runCurrent implements 'asubselect current'. It is meant to be called from
+// shell prompts, so it relies on the provider's Current without listing.
+func runCurrent(provider SubscriptionProvider, args []string, stdout, stderr io.Writer) error {
+	flags := newCommandFlags(CommandCurrent, stderr)
+	format := flags.String("format", FormatName,
+		"output format: name, tenant/name, id, short, user, alias or a Go template")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("%w: %s %s [flags]", ErrUsage, AppName, CommandCurrent)
+	}
+
+	subscription, err := provider.Current()
+	if isNotLoggedIn(err) {
+		return &ExitError{Code: ExitCodeNotLoggedIn, Err: err}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read current subscription: %w", err)
+	}
+
+	text, err := formatSubscription(subscription, *format)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, text)
+	return nil
+}
+
+// isNotLoggedIn reports whether err from Provider.Current means that there is
+// no active subscription, including when az asks for a login
+func isNotLoggedIn(err error) bool {
+	var cliErr *AzureCLIError
+	return errors.Is(err, ErrProfileNotFound) || errors.Is(err, ErrNoDefaultSubscription) ||
+		errors.As(err, &cliErr) && cliErr.Type == ErrorTypeAuth
+}
+
+// formatSubscription renders a subscription using a named format or template
+func formatSubscription(subscription Subscription, format string) (string, error) {
+	switch format {
+	case FormatName:
+		return subscription.Name, nil
+	case FormatTenantName:
+		return joinNonEmpty("/", subscription.TenantDisplayName, subscription.Name), nil
+	case FormatID:
+		return subscription.ID, nil
+	case FormatShortID:
+		return shortID(subscription.ID), nil
+	case FormatUser:
+		return subscription.User.Name, nil
+	case FormatAlias:
+		return cmp.Or(subscription.Alias, subscription.DisplayName, subscription.Name), nil
+	}
+
+	if !strings.Contains(format, "{{") {
+		return "", fmt.Errorf("%w: unknown format %q", ErrUsage, format)
+	}
+
+	tmpl, err := template.New(CommandCurrent).Parse(format)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid template: %v", ErrUsage, err)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, subscription); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return sb.String(), nil
+}
+
+// shortID returns the first block of a subscription ID
+func shortID(id string) string {
+	if len(id) <= shortIDLength {
+		return id
+	}
+	return id[:shortIDLength]
+}
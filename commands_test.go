@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunSet_UniqueMatch(t *testing.T) {
+	provider := NewSampleProvider()
+	var out bytes.Buffer
+
+	if err := runSet(DefaultConfig(), provider, []string{"the sub"}, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !strings.Contains(out.String(), SuccessMessage) {
+		t.Errorf("Expected success message, got: %s", out.String())
+	}
+
+	if current, _ := provider.Current(); current.ID != "8e9f0a1b-2c3d-4e5f-6a7b-8c9d0e1f2a3b" {
+		t.Errorf("Expected 'the Sub' to be the default, got '%s'", current.ID)
+	}
+}
+
+func TestRunSet_AlreadyActive(t *testing.T) {
+	var out bytes.Buffer
+
+	if err := runSet(DefaultConfig(), NewSampleProvider(), []string{"3f50c9e1"}, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !strings.Contains(out.String(), NoChangeMessage) {
+		t.Errorf("Expected no change message, got: %s", out.String())
+	}
+}
+
+func TestRunSet_NoMatch(t *testing.T) {
+	err := runSet(DefaultConfig(), NewSampleProvider(), []string{"xyzzy"}, io.Discard, io.Discard)
+	if !errors.Is(err, ErrNoMatch) {
+		t.Errorf("Expected ErrNoMatch, got %v", err)
+	}
+}
+
+func TestRunSet_Usage(t *testing.T) {
+	err := runSet(DefaultConfig(), NewSampleProvider(), nil, io.Discard, io.Discard)
+	if !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage, got %v", err)
+	}
+}
+
+func TestRunSet_ProviderError(t *testing.T) {
+	provider := NewFakeProvider(nil)
+	provider.ListErr = errors.New("network timeout")
+
+	if err := runSet(DefaultConfig(), provider, []string{"sub"}, io.Discard, io.Discard); !errors.Is(err, provider.ListErr) {
+		t.Errorf("Expected wrapped provider error, got %v", err)
+	}
+}
+
+func TestApp_InitialMatches(t *testing.T) {
+	subscriptions := []Subscription{
+		{ID: "3f50c9e1-0001", Name: "Prod"},
+		{ID: "3f50c9e1-0002", Name: "Dev"},
+		{ID: "9a1b2c3d-0003", Name: "Test"},
+	}
+	_, matches, err := resolveSubscription(subscriptions, "3f50")
+	if err != nil || len(matches) != 2 {
+		t.Fatalf("Expected 2 ID prefix matches, got %d: %v", len(matches), err)
+	}
+
+	app := NewApp(NewFakeProvider(subscriptions))
+	app.initialMatches = subscriptionIDs(matches)
+	app.Update(app.loadSubscriptions())
+
+	if labels := itemLabels(app.list.VisibleItems()); labels != "Prod,Dev" {
+		t.Errorf("Expected only the matches listed, got %s", labels)
+	}
+}
+
+func TestRunList_Formats(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "Names filtered by tenant",
+			args:     []string{"--output", OutputNames, "--tenant", "fabrikam"},
+			expected: "Yet Another Subscription\nthe Sub\n",
+		},
+		{
+			name:     "TSV filtered by user",
+			args:     []string{"--output", OutputTSV, "--user", "ME@FOO.COM"},
+			expected: "3f50c9e1-8f3b-4d1e-9a2b-5b8e4b4e5f1a\tSubscription 1\tContoso\tme@foo.com\ttrue\n",
+		},
+		{
+			name:     "TSV marks a single default",
+			args:     []string{"--output", OutputTSV, "--user", "goof@sample.com"},
+			expected: "8e9f0a1b-2c3d-4e5f-6a7b-8c9d0e1f2a3b\tthe Sub\tFabrikam\tgoof@sample.com\tfalse\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := runList(NewSampleProvider(), tt.args, &out, io.Discard); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if out.String() != tt.expected {
+				t.Errorf("Expected output %q, got %q", tt.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestRunList_JSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := runList(NewSampleProvider(), []string{"--output", OutputJSON}, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	subs, err := parseSubscriptions(out.Bytes())
+	if err != nil {
+		t.Fatalf("Expected JSON output to parse, got: %v", err)
+	}
+
+	if len(subs) != 5 || findDefaultSubscription(subs) != 0 {
+		t.Errorf("Expected 5 subscriptions with the first as default, got %v", subs)
+	}
+}
+
+func TestRunList_Table(t *testing.T) {
+	var out bytes.Buffer
+	if err := runList(NewSampleProvider(), nil, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Expected header and 5 rows, got %d lines", len(lines))
+	}
+
+	if !strings.HasPrefix(lines[1], "*") {
+		t.Errorf("Expected default subscription to be marked, got: %s", lines[1])
+	}
+}
+
+func TestRunList_InvalidOutput(t *testing.T) {
+	err := runList(NewSampleProvider(), []string{"--output", "yaml"}, io.Discard, io.Discard)
+	if !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage, got %v", err)
+	}
+}
+
+func TestRunCurrent_Formats(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{format: FormatName, expected: "Subscription 1\n"},
+		{format: FormatTenantName, expected: "Contoso/Subscription 1\n"},
+		{format: FormatID, expected: "3f50c9e1-8f3b-4d1e-9a2b-5b8e4b4e5f1a\n"},
+		{format: FormatShortID, expected: "3f50c9e1\n"},
+		{format: FormatUser, expected: "me@foo.com\n"},
+		{format: "{{.Name}} ({{.User.Name}})", expected: "Subscription 1 (me@foo.com)\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := runCurrent(NewSampleProvider(), []string{"--format", tt.format}, &out, io.Discard); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if out.String() != tt.expected {
+				t.Errorf("Expected output %q, got %q", tt.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestRunCurrent_InvalidFormat(t *testing.T) {
+	for _, format := range []string{"bogus", "{{.Missing"} {
+		err := runCurrent(NewSampleProvider(), []string{"--format", format}, io.Discard, io.Discard)
+		if !errors.Is(err, ErrUsage) {
+			t.Errorf("Expected ErrUsage for %q, got %v", format, err)
+		}
+	}
+}
+
+func TestRunCurrent_NotLoggedIn(t *testing.T) {
+	providers := []SubscriptionProvider{
+		NewFakeProvider([]Subscription{{ID: "sub-1"}}),
+		NewNativeProvider(t.TempDir() + "/" + AzureProfileFileName),
+	}
+	if runtime.GOOS != "windows" {
+		// An az that asks for a login, like 'az account show' before 'az login'
+		az := filepath.Join(t.TempDir(), "az")
+		script := "#!/bin/sh\necho \"ERROR: Please run 'az login' to setup account.\" >&2\nexit 1\n"
+		if err := os.WriteFile(az, []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		providers = append(providers, NewAzureCLIProvider(az))
+	}
+
+	for _, provider := range providers {
+		err := runCurrent(provider, nil, io.Discard, io.Discard)
+
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Code != ExitCodeNotLoggedIn {
+			t.Errorf("Expected exit code %d, got %v", ExitCodeNotLoggedIn, err)
+		}
+	}
+}
+
+func TestShortID(t *testing.T) {
+	if got := shortID("3f50c9e1-8f3b-4d1e-9a2b-5b8e4b4e5f1a"); got != "3f50c9e1" {
+		t.Errorf("Expected '3f50c9e1', got '%s'", got)
+	}
+
+	if got := shortID("abc"); got != "abc" {
+		t.Errorf("Expected 'abc', got '%s'", got)
+	}
+}
@@ -0,0 +1,845 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Configuration locations
+const (
+	EnvXDGConfigHome = "XDG_CONFIG_HOME"
+	EnvXDGStateHome  = "XDG_STATE_HOME"
+
+	// EnvConfig overrides the configuration file location
+	EnvConfig = "ASUBSELECT_CONFIG"
+
+	ConfigFileName = "config.toml"
+
+	// envPrefix is prepended to a setting's upper-cased key to form its
+	// environment variable, e.g. ASUBSELECT_MAX_RETRIES
+	envPrefix = "ASUBSELECT_"
+)
+
+// Where a setting's effective value came from
+const (
+	OriginDefault = "default"
+	OriginFile    = "config"
+	OriginEnv     = "env"
+	OriginFlag    = "flag"
+)
+
+// ErrInvalidConfig is returned when the configuration does not match the schema
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// xdgDir returns $<env>/asubselect, falling back to ~/<fallback>/asubselect
+func xdgDir(env, fallback string) (string, error) {
+	if dir := os.Getenv(env); dir != "" {
+		return filepath.Join(dir, AppName), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+
+	return filepath.Join(home, fallback, AppName), nil
+}
+
+// configDir returns the directory holding asubselect's configuration
+func configDir() (string, error) {
+	return xdgDir(EnvXDGConfigHome, ".config")
+}
+
+// defaultConfigPath returns the configuration file, honouring ASUBSELECT_CONFIG
+func defaultConfigPath() (string, error) {
+	if path := os.Getenv(EnvConfig); path != "" {
+		return path, nil
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, ConfigFileName), nil
+}
+
+// SubscriptionAlias is the user's short alias and display name for a subscription
+type SubscriptionAlias struct {
+	Alias       string
+	DisplayName string
+}
+
+// Config is the user's configuration. Settings are read from the
+// configuration file, then overridden by environment variables and flags.
+type Config struct {
+	Title         string
+	View          string
+	Picker        string
+	Provider      string
+	AzureCommand  string
+	Login         string
+	MaxRetries    int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	ResultTimeout time.Duration
+	Keys          KeyBindings
+
+	// FanoutCommand is run by 'fanout' when no command is given, split on spaces
+	FanoutCommand string
+
+	// KeyPreset is the key map Keys starts from, before the [keys] overrides
+	KeyPreset    string
+	keyOverrides KeyBindings
+
+	// Theme names a built-in flavor, one of Themes, the custom themes, or
+	// ThemeAuto to follow Background
+	Theme      string
+	Themes     map[string]Theme
+	Background string
+
+	// Aliases are keyed by lower-cased subscription ID, so they survive renames
+	Aliases map[string]SubscriptionAlias
+
+	// Path is the configuration file, which need not exist
+	Path string
+
+	// origins records where each setting's value came from
+	origins map[string]string
+}
+
+// DefaultConfig returns the built-in configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Title:         AppTitle,
+		View:          ViewFlat,
+		Picker:        PickerSubscription,
+		Provider:      ProviderAuto,
+		AzureCommand:  AzureCommand,
+		Login:         LoginBrowser,
+		MaxRetries:    MaxRetries,
+		BaseDelay:     BaseDelay,
+		MaxDelay:      MaxDelay,
+		ResultTimeout: ResultTimeout,
+		Keys:          DefaultKeyBindings(),
+		KeyPreset:     KeyMapDefault,
+		Theme:         ThemeAuto,
+		Background:    BackgroundAuto,
+		origins:       map[string]string{},
+	}
+}
+
+// Setting kinds, used for validation and TOML output
+const (
+	kindString   = "string"
+	kindInteger  = "integer"
+	kindDuration = "duration"
+)
+
+// setting describes a scalar setting that can be overridden by an
+// environment variable and a flag
+type setting struct {
+	key   string
+	kind  string
+	usage string
+	get   func(c *Config) string
+	set   func(c *Config, value string) error
+}
+
+// Env returns the environment variable overriding the setting
+func (s setting) Env() string {
+	return envPrefix + strings.ToUpper(s.key)
+}
+
+// Flag returns the name of the flag overriding the setting
+func (s setting) Flag() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// settings is the configuration schema for scalar settings
+var settings = []setting{
+	{
+		key: "title", kind: kindString, usage: "title shown above the subscription list",
+		get: func(c *Config) string { return c.Title },
+		set: func(c *Config, value string) error {
+			c.Title = value
+			return nil
+		},
+	},
+	{
+		key: "view", kind: kindString, usage: "subscription list: flat, or tenant to group by tenant",
+		get: func(c *Config) string { return c.View },
+		set: func(c *Config, value string) error {
+			value = strings.ToLower(value)
+			if !slices.Contains(viewModes, value) {
+				return fmt.Errorf("must be one of %s", strings.Join(viewModes, ", "))
+			}
+			c.View = value
+			return nil
+		},
+	},
+	{
+		key: "picker", kind: kindString, usage: "first screen: subscription, or tenant to pick a tenant first",
+		get: func(c *Config) string { return c.Picker },
+		set: func(c *Config, value string) error {
+			value = strings.ToLower(value)
+			if !slices.Contains(pickerModes, value) {
+				return fmt.Errorf("must be one of %s", strings.Join(pickerModes, ", "))
+			}
+			c.Picker = value
+			return nil
+		},
+	},
+	{
+		key: "provider", kind: kindString, usage: "subscription backend: auto, native, az or sample",
+		get: func(c *Config) string { return c.Provider },
+		set: func(c *Config, value string) error {
+			if !slices.Contains(providerNames, value) {
+				return fmt.Errorf("must be one of %s", strings.Join(providerNames, ", "))
+			}
+			c.Provider = value
+			return nil
+		},
+	},
+	{
+		key: "az_command", kind: kindString, usage: "Azure CLI executable",
+		get: func(c *Config) string { return c.AzureCommand },
+		set: func(c *Config, value string) error {
+			if strings.TrimSpace(value) == "" {
+				return errors.New("must not be empty")
+			}
+			c.AzureCommand = value
+			return nil
+		},
+	},
+	{
+		key: "login", kind: kindString, usage: "how 'az login' signs in from the TUI: browser or device",
+		get: func(c *Config) string { return c.Login },
+		set: func(c *Config, value string) error {
+			value = strings.ToLower(value)
+			if !slices.Contains(loginMethods, value) {
+				return fmt.Errorf("must be one of %s", strings.Join(loginMethods, ", "))
+			}
+			c.Login = value
+			return nil
+		},
+	},
+	{
+		key: "theme", kind: kindString, usage: "color theme: auto, latte, frappe, macchiato, mocha or a custom theme",
+		get: func(c *Config) string { return c.Theme },
+		set: func(c *Config, value string) error {
+			return c.setTheme(value)
+		},
+	},
+	{
+		key: "background", kind: kindString, usage: "terminal background for the auto theme: auto (detect), light or dark",
+		get: func(c *Config) string { return c.Background },
+		set: func(c *Config, value string) error {
+			value = strings.ToLower(value)
+			if !slices.Contains(backgroundNames, value) {
+				return fmt.Errorf("must be one of %s", strings.Join(backgroundNames, ", "))
+			}
+			c.Background = value
+			return nil
+		},
+	},
+	{
+		key: "keymap", kind: kindString, usage: "key binding preset: default, vim or emacs",
+		get: func(c *Config) string { return c.KeyPreset },
+		set: func(c *Config, value string) error {
+			value = strings.ToLower(value)
+			if !slices.Contains(keyMapPresets, value) {
+				return fmt.Errorf("must be one of %s", strings.Join(keyMapPresets, ", "))
+			}
+			previous := c.KeyPreset
+			c.KeyPreset = value
+			if err := c.updateKeys(); err != nil {
+				c.KeyPreset = previous
+				return err
+			}
+			return nil
+		},
+	},
+	{
+		key: "fanout_command", kind: kindString, usage: "command 'fanout' runs when none follows --, split on spaces",
+		get: func(c *Config) string { return c.FanoutCommand },
+		set: func(c *Config, value string) error {
+			c.FanoutCommand = strings.TrimSpace(value)
+			return nil
+		},
+	},
+	{
+		key: "max_retries", kind: kindInteger, usage: "attempts before giving up on transient errors",
+		get: func(c *Config) string { return strconv.Itoa(c.MaxRetries) },
+		set: func(c *Config, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 10 {
+				return errors.New("must be an integer from 0 to 10")
+			}
+			c.MaxRetries = n
+			return nil
+		},
+	},
+	{
+		key: "base_delay", kind: kindDuration, usage: "delay before the first retry, doubled on every attempt",
+		get: func(c *Config) string { return c.BaseDelay.String() },
+		set: func(c *Config, value string) error {
+			return setDuration(&c.BaseDelay, value, time.Minute)
+		},
+	},
+	{
+		key: "max_delay", kind: kindDuration, usage: "longest delay between retries",
+		get: func(c *Config) string { return c.MaxDelay.String() },
+		set: func(c *Config, value string) error {
+			return setDuration(&c.MaxDelay, value, time.Minute)
+		},
+	},
+	{
+		key: "result_timeout", kind: kindDuration, usage: "how long the result page is shown",
+		get: func(c *Config) string { return c.ResultTimeout.String() },
+		set: func(c *Config, value string) error {
+			return setDuration(&c.ResultTimeout, value, time.Minute)
+		},
+	},
+}
+
+// setDuration parses a positive duration no longer than limit
+func setDuration(target *time.Duration, value string, limit time.Duration) error {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 || d > limit {
+		return fmt.Errorf("must be a duration like \"500ms\", greater than 0 and at most %s", limit)
+	}
+	*target = d
+	return nil
+}
+
+// findSetting returns the setting with the given TOML key
+func findSetting(key string) (setting, bool) {
+	index := slices.IndexFunc(settings, func(s setting) bool { return s.key == key })
+	if index < 0 {
+		return setting{}, false
+	}
+	return settings[index], true
+}
+
+// Origin returns where the setting with the given key came from
+func (c *Config) Origin(key string) string {
+	if source, ok := c.origins[key]; ok {
+		return source
+	}
+	return OriginDefault
+}
+
+// LoadConfig reads the configuration file at path on top of the defaults.
+// A missing file yields the defaults.
+func LoadConfig(path string) (*Config, error) {
+	config := DefaultConfig()
+	config.Path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if err := config.decode(string(data)); err != nil {
+		config = DefaultConfig()
+		config.Path = path
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// loadUserConfig loads the configuration file at path, or the default
+// location if empty, then applies environment variables and the given flag
+// overrides. Problems with the file are reported on stderr and the file is
+// ignored, but invalid overrides are errors.
+func loadUserConfig(path string, overrides map[string]string, stderr io.Writer) (*Config, error) {
+	if path == "" {
+		var err error
+		if path, err = defaultConfigPath(); err != nil {
+			fmt.Fprintf(stderr, "Warning: %v\n", err)
+		}
+	}
+
+	config := DefaultConfig()
+	if path != "" {
+		var err error
+		if config, err = LoadConfig(path); err != nil {
+			fmt.Fprintf(stderr, "Warning: %v\n", err)
+		}
+	}
+
+	if err := config.applyEnv(); err != nil {
+		return config, err
+	}
+	if err := config.applyOverrides(overrides); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// applyEnv overrides settings from their environment variables
+func (c *Config) applyEnv() error {
+	for _, s := range settings {
+		value := os.Getenv(s.Env())
+		if value == "" {
+			continue
+		}
+		if err := s.set(c, value); err != nil {
+			return fmt.Errorf("%w: %s %v", ErrInvalidConfig, s.Env(), err)
+		}
+		c.origins[s.key] = OriginEnv
+	}
+	return nil
+}
+
+// applyOverrides overrides settings from flags, keyed by setting key
+func (c *Config) applyOverrides(overrides map[string]string) error {
+	for _, key := range slices.Sorted(maps.Keys(overrides)) {
+		s, ok := findSetting(key)
+		if !ok {
+			return fmt.Errorf("%w: unknown setting %q", ErrInvalidConfig, key)
+		}
+		if err := s.set(c, overrides[key]); err != nil {
+			return fmt.Errorf("%w: --%s %v", ErrUsage, s.Flag(), err)
+		}
+		c.origins[key] = OriginFlag
+	}
+	return nil
+}
+
+// decode fills the configuration from TOML, validating it against the schema
+func (c *Config) decode(data string) error {
+	var document map[string]any
+	if err := toml.Unmarshal([]byte(data), &document); err != nil {
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			line, _ := decodeErr.Position()
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		return err
+	}
+
+	// Custom themes are decoded first, so the theme setting can refer to them
+	if value, ok := document["themes"]; ok {
+		if err := c.decodeThemes(value); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(document)) {
+		value := document[key]
+		var err error
+		switch key {
+		case "themes":
+			continue
+		case "keys":
+			err = c.decodeKeys(value)
+		case "aliases":
+			err = c.decodeAliases(value)
+		default:
+			err = c.decodeSetting(key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeSetting decodes a scalar setting, checking its type
+func (c *Config) decodeSetting(key string, value any) error {
+	s, ok := findSetting(key)
+	if !ok {
+		return fmt.Errorf("%w: unknown key %q", ErrInvalidConfig, key)
+	}
+
+	var text string
+	switch v := value.(type) {
+	case string:
+		if s.kind == kindInteger {
+			return fmt.Errorf("%w: %s must be an integer", ErrInvalidConfig, key)
+		}
+		text = v
+	case int64:
+		if s.kind != kindInteger {
+			return fmt.Errorf("%w: %s must be a string", ErrInvalidConfig, key)
+		}
+		text = strconv.FormatInt(v, 10)
+	default:
+		return fmt.Errorf("%w: %s must be a %s", ErrInvalidConfig, key, s.kind)
+	}
+
+	if err := s.set(c, text); err != nil {
+		return fmt.Errorf("%w: %s %v", ErrInvalidConfig, key, err)
+	}
+	c.origins[key] = OriginFile
+	return nil
+}
+
+// decodeKeys decodes the [keys] table. Each action takes a key or a list of keys.
+func (c *Config) decodeKeys(value any) error {
+	table, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%w: keys must be a table", ErrInvalidConfig)
+	}
+
+	actions := c.keyOverrides.actions()
+	for _, action := range slices.Sorted(maps.Keys(table)) {
+		binding, ok := actions[action]
+		if !ok {
+			return fmt.Errorf("%w: unknown key binding keys.%s", ErrInvalidConfig, action)
+		}
+
+		var keys []string
+		switch v := table[action].(type) {
+		case string:
+			keys = []string{v}
+		case []any:
+			for _, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("%w: keys.%s must only contain strings", ErrInvalidConfig, action)
+				}
+				keys = append(keys, s)
+			}
+		default:
+			return fmt.Errorf("%w: keys.%s must be a string or a list of strings", ErrInvalidConfig, action)
+		}
+
+		if len(keys) == 0 || slices.Contains(keys, "") {
+			return fmt.Errorf("%w: keys.%s must not be empty", ErrInvalidConfig, action)
+		}
+		for i, key := range keys {
+			if key == "space" {
+				keys[i] = " "
+			}
+		}
+		*binding = keys
+	}
+
+	if err := c.updateKeys(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return nil
+}
+
+// updateKeys applies the [keys] overrides to the preset, rejecting keys bound
+// to two actions that are available at the same time
+func (c *Config) updateKeys() error {
+	keys := presetKeyBindings(c.KeyPreset).merge(c.keyOverrides)
+	if err := keys.conflicts(); err != nil {
+		return err
+	}
+	c.Keys = keys
+	return nil
+}
+
+// decodeAliases decodes the [aliases."<subscription-id>"] tables
+func (c *Config) decodeAliases(value any) error {
+	tables, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%w: aliases must be a table", ErrInvalidConfig)
+	}
+
+	c.Aliases = make(map[string]SubscriptionAlias, len(tables))
+	owners := make(map[string]string)
+
+	// Sorted, so a duplicate alias is always reported the same way
+	for _, id := range slices.Sorted(maps.Keys(tables)) {
+		table, ok := tables[id].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: aliases.%q must be a table", ErrInvalidConfig, id)
+		}
+
+		var alias SubscriptionAlias
+		for key, value := range table {
+			s, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%w: aliases.%q.%s must be a string", ErrInvalidConfig, id, key)
+			}
+
+			switch key {
+			case "alias":
+				alias.Alias = strings.TrimSpace(s)
+			case "name":
+				alias.DisplayName = strings.TrimSpace(s)
+			default:
+				return fmt.Errorf("%w: unknown key aliases.%q.%s", ErrInvalidConfig, id, key)
+			}
+		}
+
+		if alias.Alias != "" {
+			lower := strings.ToLower(alias.Alias)
+			if owner, taken := owners[lower]; taken {
+				return fmt.Errorf("%w: alias %q is used for both %s and %s", ErrInvalidConfig, alias.Alias, owner, id)
+			}
+			owners[lower] = id
+		}
+
+		c.Aliases[strings.ToLower(id)] = alias
+	}
+
+	return nil
+}
+
+// applyAliases returns subscriptions annotated with the configured aliases
+func (c *Config) applyAliases(subscriptions []Subscription) []Subscription {
+	if len(c.Aliases) == 0 {
+		return subscriptions
+	}
+
+	annotated := slices.Clone(subscriptions)
+	for i := range annotated {
+		annotated[i] = c.applyAlias(annotated[i])
+	}
+	return annotated
+}
+
+// applyAlias annotates a single subscription with its configured alias
+func (c *Config) applyAlias(subscription Subscription) Subscription {
+	if alias, ok := c.Aliases[strings.ToLower(subscription.ID)]; ok {
+		subscription.Alias = alias.Alias
+		subscription.DisplayName = alias.DisplayName
+	}
+	return subscription
+}
+
+// withAliases wraps provider so listed subscriptions carry their aliases
+func (c *Config) withAliases(provider SubscriptionProvider) SubscriptionProvider {
+	if len(c.Aliases) == 0 {
+		return provider
+	}
+	return &aliasProvider{SubscriptionProvider: provider, config: c}
+}
+
+// aliasProvider annotates subscriptions from another provider with aliases
+type aliasProvider struct {
+	SubscriptionProvider
+	config *Config
+}
+
+// List implements SubscriptionProvider
+func (p *aliasProvider) List() ([]Subscription, error) {
+	subscriptions, err := p.SubscriptionProvider.List()
+	return p.config.applyAliases(subscriptions), err
+}
+
+// Current implements SubscriptionProvider
+func (p *aliasProvider) Current() (Subscription, error) {
+	subscription, err := p.SubscriptionProvider.Current()
+	return p.config.applyAlias(subscription), err
+}
+
+// Unwrap returns the provider the aliases are applied to
+func (p *aliasProvider) Unwrap() SubscriptionProvider {
+	return p.SubscriptionProvider
+}
+
+// Config subcommands
+const (
+	CommandConfig  = "config"
+	configShow     = "show"
+	configEdit     = "edit"
+	configValidate = "validate"
+
+	// defaultEditor is used when neither $VISUAL nor $EDITOR is set
+	defaultEditor = "vi"
+)
+
+// runConfig implements 'asubselect config show|edit|validate'
+func runConfig(config *Config, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	usage := fmt.Errorf("%w: %s %s show|edit|validate [path]", ErrUsage, AppName, CommandConfig)
+	if len(args) == 0 || len(args) > 2 {
+		return usage
+	}
+
+	path := config.Path
+	if len(args) == 2 {
+		path = args[1]
+	}
+
+	switch args[0] {
+	case configShow:
+		if len(args) == 2 {
+			return usage
+		}
+		return writeConfig(stdout, config)
+	case configValidate:
+		return validateConfig(path, stdout)
+	case configEdit:
+		return editConfig(path, stdin, stdout, stderr)
+	default:
+		return usage
+	}
+}
+
+// validateConfig checks the configuration file at path against the schema
+func validateConfig(path string, stdout io.Writer) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(stdout, "%s does not exist, the defaults are used\n", path)
+		return nil
+	}
+
+	if _, err := LoadConfig(path); err != nil {
+		return &ExitError{Code: ExitCodeError, Err: err}
+	}
+
+	fmt.Fprintf(stdout, "%s is valid\n", path)
+	return nil
+}
+
+// editConfig opens the configuration file in the user's editor, creating it
+// from a commented template first, and validates the result
+func editConfig(path string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+
+		var template strings.Builder
+		writeConfigTemplate(&template)
+		if err := writeFileAtomic(path, []byte(template.String())); err != nil {
+			return err
+		}
+	}
+
+	editor := strings.Fields(cmp.Or(os.Getenv("VISUAL"), os.Getenv("EDITOR"), defaultEditor))
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+	if err := runChild(cmd); err != nil {
+		return err
+	}
+
+	return validateConfig(path, stdout)
+}
+
+// writeConfig writes the effective configuration as TOML, noting where each
+// setting came from
+func writeConfig(out io.Writer, config *Config) error {
+	fmt.Fprintf(out, "# Effective configuration, file: %s\n\n", config.Path)
+
+	for _, s := range settings {
+		origin := config.Origin(s.key)
+		switch origin {
+		case OriginEnv:
+			origin += " " + s.Env()
+		case OriginFlag:
+			origin += " --" + s.Flag()
+		}
+		fmt.Fprintf(out, "%s = %s # %s\n", s.key, tomlValue(s, s.get(config)), origin)
+	}
+
+	fmt.Fprintln(out, "\n[keys]")
+	writeKeyBindings(out, config.Keys, "")
+
+	writeThemes(out, config.Themes)
+
+	for _, id := range slices.Sorted(maps.Keys(config.Aliases)) {
+		alias := config.Aliases[id]
+		fmt.Fprintf(out, "\n[aliases.%s]\n", tomlString(id))
+		if alias.Alias != "" {
+			fmt.Fprintf(out, "alias = %s\n", tomlString(alias.Alias))
+		}
+		if alias.DisplayName != "" {
+			fmt.Fprintf(out, "name = %s\n", tomlString(alias.DisplayName))
+		}
+	}
+
+	return nil
+}
+
+// writeConfigTemplate writes a configuration file with every setting
+// commented out at its default
+func writeConfigTemplate(out io.Writer) {
+	defaults := DefaultConfig()
+
+	fmt.Fprintf(out, "# %s configuration. Every setting is optional and shown with its default.\n", AppName)
+	fmt.Fprintln(out, "# Settings can also be overridden by environment variables and flags.")
+
+	for _, s := range settings {
+		fmt.Fprintf(out, "\n# %s (%s, --%s)\n", s.usage, s.Env(), s.Flag())
+		fmt.Fprintf(out, "# %s = %s\n", s.key, tomlValue(s, s.get(defaults)))
+	}
+
+	fmt.Fprintln(out, "\n# Key bindings, each a key or a list of keys, on top of the keymap preset")
+	fmt.Fprintln(out, "# [keys]")
+	writeKeyBindings(out, defaults.Keys, "# ")
+
+	fmt.Fprintln(out, "\n# Custom themes start from a flavor and override palette entries with hex")
+	fmt.Fprintln(out, "# colors, and roles with palette entries or hex colors. Select one with theme.")
+	fmt.Fprintln(out, "# [themes.custom]")
+	fmt.Fprintln(out, "# flavor = \"latte\"")
+	fmt.Fprintln(out, "# [themes.custom.palette]")
+	fmt.Fprintln(out, "# lavender = \"#7287fd\"")
+	fmt.Fprintln(out, "# [themes.custom.roles]")
+	fmt.Fprintln(out, "# accent = \"mauve\"")
+
+	fmt.Fprintln(out, "\n# Aliases and display names, keyed by subscription ID")
+	fmt.Fprintln(out, "# [aliases.\"00000000-0000-0000-0000-000000000000\"]")
+	fmt.Fprintln(out, "# alias = \"dev\"")
+	fmt.Fprintln(out, "# name = \"Development\"")
+}
+
+// writeKeyBindings writes the body of a [keys] table, each line prefixed
+func writeKeyBindings(out io.Writer, keys KeyBindings, prefix string) {
+	actions := keys.actions()
+	for _, action := range slices.Sorted(maps.Keys(actions)) {
+		quoted := make([]string, len(*actions[action]))
+		for i, key := range *actions[action] {
+			if key == " " {
+				key = "space"
+			}
+			quoted[i] = tomlString(key)
+		}
+		fmt.Fprintf(out, "%s%s = [%s]\n", prefix, action, strings.Join(quoted, ", "))
+	}
+}
+
+// tomlValue formats a setting's value as TOML
+func tomlValue(s setting, value string) string {
+	if s.kind == kindInteger {
+		return value
+	}
+	return tomlString(value)
+}
+
+// tomlString quotes s as a TOML basic string
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestMain points the XDG directories at a temporary directory, so tests
+// never read or write the user's favorites and history
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", AppName+"-test-*")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv(EnvXDGConfigHome, filepath.Join(dir, "config"))
+	os.Setenv(EnvXDGStateHome, filepath.Join(dir, "state"))
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestXDGDir(t *testing.T) {
+	t.Setenv(EnvXDGStateHome, "/xdg/state")
+	if dir, err := stateDir(); err != nil || dir != filepath.Join("/xdg/state", AppName) {
+		t.Errorf("Expected the XDG state directory, got %q (%v)", dir, err)
+	}
+
+	t.Setenv(EnvXDGConfigHome, "")
+	t.Setenv("HOME", "/home/tester")
+	if dir, err := configDir(); err != nil || dir != filepath.Join("/home/tester", ".config", AppName) {
+		t.Errorf("Expected the default config directory, got %q (%v)", dir, err)
+	}
+}
+
+// writeTestConfig writes a configuration file and returns its path
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), ConfigFileName)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig_Missing(t *testing.T) {
+	config, err := LoadConfig(filepath.Join(t.TempDir(), ConfigFileName))
+	if err != nil || len(config.Aliases) != 0 {
+		t.Errorf("Expected an empty configuration, got %+v (%v)", config, err)
+	}
+}
+
+func TestLoadConfig_Aliases(t *testing.T) {
+	path := writeTestConfig(t, `
+[aliases."3F50C9E1-8F3B-4D1E-9A2B-5B8E4B4E5F1A"]
+alias = "plat-np"
+name = "Platform non-prod"
+
+[aliases]
+"8e9f0a1b-2c3d-4e5f-6a7b-8c9d0e1f2a3b" = { alias = "sandbox" }
+`)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	subscriptions, err := config.withAliases(NewSampleProvider()).List()
+	if err != nil {
+		t.Fatalf("Expected no error listing, got: %v", err)
+	}
+
+	first := subscriptions[0]
+	if first.Alias != "plat-np" || first.DisplayName != "Platform non-prod" {
+		t.Errorf("Expected alias matched case-insensitively by ID, got %+v", first)
+	}
+	if first.Title() != "Contoso / Platform non-prod [plat-np]" {
+		t.Errorf("Expected the display name and alias in the title, got %q", first.Title())
+	}
+	if !strings.Contains(first.FilterValue(), "plat-np") || !strings.Contains(first.FilterValue(), "Subscription 1") {
+		t.Errorf("Expected alias and original name to be searchable, got %q", first.FilterValue())
+	}
+
+	if sub, matches, err := resolveSubscription(subscriptions, "SANDBOX"); err != nil || len(matches) != 1 || sub.Name != "the Sub" {
+		t.Errorf("Expected alias query to resolve 'the Sub', got %+v (%v)", sub, err)
+	}
+	if sub, _, err := resolveSubscription(subscriptions, "platform non-prod"); err != nil || sub.Alias != "plat-np" {
+		t.Errorf("Expected display name query to resolve, got %+v (%v)", sub, err)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown = 1":                     "unknown key",
+		"aliases = 1":                     "aliases must be a table",
+		"[aliases.sub-1]\nalias = 1":      "must be a string",
+		"[aliases.sub-1]\nnickname = 'x'": "unknown key aliases",
+		"[aliases.sub-1]\nalias = 'x'\n[aliases.sub-2]\nalias = 'X'": "alias \"X\" is used for both sub-1 and sub-2",
+		"[aliases": "line 1",
+		// Tables defined by dotted keys cannot be reopened with a header
+		"[aliases]\nsub-1.alias = 'x'\n[aliases.sub-1]": "table sub-1 already exists",
+	}
+
+	for content, expected := range tests {
+		_, err := LoadConfig(writeTestConfig(t, content))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error containing %q for %q, got %v", expected, content, err)
+		}
+	}
+}
+
+func TestLoadConfig_Settings(t *testing.T) {
+	path := writeTestConfig(t, `
+title = "Pick one"
+provider = "az"
+az_command = "/opt/az/bin/az"
+max_retries = 5
+base_delay = "250ms"
+max_delay = "2s"
+result_timeout = "3s"
+fanout_command = "az group list -o table"
+
+[keys]
+quit = "ctrl+q"
+toggle = ["space", "x"]
+`)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if config.Title != "Pick one" || config.Provider != ProviderAzureCLI || config.AzureCommand != "/opt/az/bin/az" {
+		t.Errorf("Expected string settings from the file, got %+v", config)
+	}
+	if config.FanoutCommand != "az group list -o table" {
+		t.Errorf("Expected the fan-out command from the file, got %q", config.FanoutCommand)
+	}
+	if config.MaxRetries != 5 || config.BaseDelay != 250*time.Millisecond || config.MaxDelay != 2*time.Second ||
+		config.ResultTimeout != 3*time.Second {
+		t.Errorf("Expected numeric settings from the file, got %+v", config)
+	}
+	if !slices.Equal(config.Keys.Quit, []string{"ctrl+q"}) || !slices.Equal(config.Keys.Toggle, []string{" ", "x"}) {
+		t.Errorf("Expected key bindings from the file, got %+v", config.Keys)
+	}
+	if !slices.Equal(config.Keys.Select, []string{KeyEnter}) {
+		t.Errorf("Expected unset bindings to keep their defaults, got %v", config.Keys.Select)
+	}
+	if config.Origin("title") != OriginFile || config.Origin("unknown") != OriginDefault {
+		t.Error("Expected origins to be tracked")
+	}
+}
+
+func TestLoadConfig_Schema(t *testing.T) {
+	tests := map[string]string{
+		"max_retries = \"3\"":      "max_retries must be an integer",
+		"max_retries = 11":         "max_retries must be an integer from 0 to 10",
+		"base_delay = 500":         "base_delay must be a string",
+		"base_delay = \"soon\"":    "base_delay must be a duration",
+		"result_timeout = \"-1s\"": "result_timeout must be a duration",
+		"title = true":             "title must be a string",
+		"provider = \"azure\"":     "provider must be one of",
+		"az_command = \" \"":       "az_command must not be empty",
+		"keys = 1":                 "keys must be a table",
+		"[keys]\nquit = []":        "keys.quit must not be empty",
+		"[keys]\nquit = [1]":       "keys.quit must only contain strings",
+		"[keys]\njump = \"j\"":     "unknown key binding keys.jump",
+	}
+
+	for content, expected := range tests {
+		_, err := LoadConfig(writeTestConfig(t, content))
+		if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected schema error containing %q for %q, got %v", expected, content, err)
+		}
+	}
+}
+
+func TestLoadUserConfig_Precedence(t *testing.T) {
+	path := writeTestConfig(t, "max_retries = 5\nbase_delay = \"1s\"\ntitle = \"From file\"\n")
+	t.Setenv("ASUBSELECT_MAX_RETRIES", "6")
+	t.Setenv("ASUBSELECT_BASE_DELAY", "2s")
+
+	config, err := loadUserConfig(path, map[string]string{"base_delay": "3s"}, io.Discard)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if config.Title != "From file" || config.Origin("title") != OriginFile {
+		t.Errorf("Expected title from the file, got %q (%s)", config.Title, config.Origin("title"))
+	}
+	if config.MaxRetries != 6 || config.Origin("max_retries") != OriginEnv {
+		t.Errorf("Expected max_retries from the environment, got %d (%s)", config.MaxRetries, config.Origin("max_retries"))
+	}
+	if config.BaseDelay != 3*time.Second || config.Origin("base_delay") != OriginFlag {
+		t.Errorf("Expected base_delay from the flag, got %s (%s)", config.BaseDelay, config.Origin("base_delay"))
+	}
+
+	if _, err := loadUserConfig(path, map[string]string{"max_retries": "many"}, io.Discard); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage for an invalid flag, got %v", err)
+	}
+
+	t.Setenv("ASUBSELECT_PROVIDER", "bogus")
+	if _, err := loadUserConfig(path, nil, io.Discard); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig for an invalid variable, got %v", err)
+	}
+}
+
+func TestLoadUserConfig_InvalidFile(t *testing.T) {
+	var stderr bytes.Buffer
+	config, err := loadUserConfig(writeTestConfig(t, "max_retries = -1"), nil, &stderr)
+	if err != nil {
+		t.Fatalf("Expected an invalid file to be ignored, got: %v", err)
+	}
+
+	if config.MaxRetries != MaxRetries || !strings.Contains(stderr.String(), "Warning:") {
+		t.Errorf("Expected defaults and a warning, got %d and %q", config.MaxRetries, stderr.String())
+	}
+}
+
+func TestRunConfig_Show(t *testing.T) {
+	path := writeTestConfig(t, "[aliases.\"sub-1\"]\nalias = \"a \\\"quoted\\\" alias\"\n")
+	config, err := loadUserConfig(path, map[string]string{"title": "Flagged"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runConfig(config, []string{"show"}, nil, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	for _, expected := range []string{`title = "Flagged" # flag --title`, "max_retries = 3 # default", `toggle = ["space"]`} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+
+	// The output is itself a valid configuration
+	shown := DefaultConfig()
+	if err := shown.decode(out.String()); err != nil {
+		t.Fatalf("Expected shown configuration to be valid, got: %v\n%s", err, out.String())
+	}
+	if shown.Title != "Flagged" || shown.Aliases["sub-1"].Alias != `a "quoted" alias` || !slices.Equal(shown.Keys.Toggle, []string{" "}) {
+		t.Errorf("Expected shown configuration to round-trip, got %+v", shown)
+	}
+}
+
+func TestRunConfig_Validate(t *testing.T) {
+	var out bytes.Buffer
+	valid := writeTestConfig(t, "max_retries = 2")
+	if err := runConfig(DefaultConfig(), []string{"validate", valid}, nil, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(out.String(), "is valid") {
+		t.Errorf("Expected confirmation, got: %s", out.String())
+	}
+
+	config := DefaultConfig()
+	config.Path = writeTestConfig(t, "max_retries = 20")
+	var exitErr *ExitError
+	if err := runConfig(config, []string{"validate"}, nil, io.Discard, io.Discard); !errors.As(err, &exitErr) || !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected an ExitError wrapping ErrInvalidConfig, got %v", err)
+	}
+
+	if err := runConfig(config, []string{"bogus"}, nil, io.Discard, io.Discard); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage, got %v", err)
+	}
+}
+
+func TestRunConfig_Edit(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "true")
+
+	path := filepath.Join(t.TempDir(), "new", ConfigFileName)
+	var out bytes.Buffer
+	if err := runConfig(DefaultConfig(), []string{"edit", path}, nil, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	template, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected a template to be created, got: %v", err)
+	}
+	if !strings.Contains(string(template), "# max_retries = 3") || !strings.Contains(out.String(), "is valid") {
+		t.Errorf("Expected a commented template that validates, got:\n%s\n%s", template, out.String())
+	}
+
+	// Uncommenting every line of the template yields a valid configuration
+	var uncommented strings.Builder
+	for _, line := range strings.Split(string(template), "\n") {
+		if strings.HasPrefix(line, "# ") && (strings.Contains(line, " = ") || strings.HasPrefix(line, "# [")) {
+			line = strings.TrimPrefix(line, "# ")
+		}
+		uncommented.WriteString(line + "\n")
+	}
+	if err := DefaultConfig().decode(uncommented.String()); err != nil {
+		t.Errorf("Expected the uncommented template to be valid, got: %v\n%s", err, uncommented.String())
+	}
+}
+
+func TestNewUserApp_Config(t *testing.T) {
+	config := DefaultConfig()
+	config.Title = "Custom title"
+	config.Keys.Quit = []string{"x"}
+
+	app := newUserApp(config, NewSampleProvider())
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+
+	if app.list.Title != "Custom title" {
+		t.Errorf("Expected configured title, got %q", app.list.Title)
+	}
+
+	if _, cmd := app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'q'}}); cmd != nil {
+		if _, ok := cmd().(tea.QuitMsg); ok {
+			t.Error("Expected 'q' not to quit once remapped")
+		}
+	}
+	if _, cmd := app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}}); cmd == nil {
+		t.Error("Expected remapped quit key to quit")
+	} else if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("Expected remapped quit key to quit")
+	}
+}
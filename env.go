@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Env configuration
+const (
+	CommandEnv = "env"
+
+	// KeyEnv prints export statements for the highlighted subscription and quits
+	KeyEnv = "e"
+
+	EnvAzureTenantID = "AZURE_TENANT_ID"
+	EnvUser          = "ASUBSELECT_USER"
+
+	ShellPosix      = "posix"
+	ShellFish       = "fish"
+	ShellPowerShell = "powershell"
+	ShellDotenv     = "dotenv"
+)
+
+var envShells = []string{ShellPosix, PromptBash, PromptZsh, ShellFish, ShellPowerShell, ShellDotenv}
+
+// envVariable is a single variable exported for a subscription
+type envVariable struct {
+	Name  string
+	Value string
+}
+
+// subscriptionVariables returns the variables tools like terraform, pulumi
+// and the Azure SDKs read to pick a subscription, in a stable order
+func subscriptionVariables(subscription Subscription) []envVariable {
+	return []envVariable{
+		{Name: EnvAzureSubscriptionID, Value: subscription.ID},
+		{Name: EnvARMSubscriptionID, Value: subscription.ID},
+		{Name: EnvAzureTenantID, Value: subscription.TenantID},
+		{Name: EnvARMTenantID, Value: subscription.TenantID},
+		{Name: EnvUser, Value: subscription.User.Name},
+	}
+}
+
+// detectShell guesses the export syntax from $SHELL
+func detectShell() string {
+	switch name := strings.TrimSuffix(filepath.Base(os.Getenv("SHELL")), ".exe"); name {
+	case ShellFish:
+		return ShellFish
+	case "pwsh", ShellPowerShell:
+		return ShellPowerShell
+	default:
+		return ShellPosix
+	}
+}
+
+// writeEnv writes statements setting, or with unset clearing, the
+// subscription variables in the given shell's syntax
+func writeEnv(out io.Writer, shell string, subscription Subscription, unset bool) error {
+	for _, v := range subscriptionVariables(subscription) {
+		var line string
+		switch shell {
+		case ShellPosix, PromptBash, PromptZsh:
+			line = "export " + v.Name + "=" + posixQuote(v.Value)
+			if unset {
+				line = "unset " + v.Name
+			}
+		case ShellFish:
+			line = "set -gx " + v.Name + " " + posixQuote(v.Value) + ";"
+			if unset {
+				line = "set -e " + v.Name + ";"
+			}
+		case ShellPowerShell:
+			line = "$Env:" + v.Name + " = '" + strings.ReplaceAll(v.Value, "'", "''") + "'"
+			if unset {
+				line = "Remove-Item Env:" + v.Name + " -ErrorAction SilentlyContinue"
+			}
+		case ShellDotenv:
+			line = v.Name + "=" + dotenvQuote(v.Value)
+			if unset {
+				line = v.Name + "="
+			}
+		default:
+			return fmt.Errorf("%w: unknown shell %q", ErrUsage, shell)
+		}
+
+		fmt.Fprintln(out, line)
+	}
+
+	return nil
+}
+
+// posixQuote single-quotes a value for POSIX shells and fish
+func posixQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// dotenvQuote double-quotes a value for dotenv files, so spaces and '#' are
+// kept as part of it
+func dotenvQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+// runEnv implements 'asubselect env [query]'. Without a query the TUI is shown
+// on stderr, so the command can be used as eval "$(asubselect env)".
+func runEnv(config *Config, provider SubscriptionProvider, args []string, stdout, stderr io.Writer) error {
+	flags := newCommandFlags(CommandEnv, stderr)
+	shell := flags.String("shell", detectShell(), "syntax: "+strings.Join(envShells, ", "))
+	unset := flags.Bool("unset", false, "print statements clearing the variables instead")
+	current := flags.Bool("current", false, "use the active subscription instead of asking")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("%w: %s %s [flags] [query]", ErrUsage, AppName, CommandEnv)
+	}
+
+	if *unset {
+		return writeEnv(stdout, *shell, Subscription{}, true)
+	}
+	if err := writeEnv(io.Discard, *shell, Subscription{}, false); err != nil {
+		return err
+	}
+
+	subscription, err := chooseEnvSubscription(config, provider, flags.Arg(0), *current, stderr)
+	if errors.Is(err, errNothingChosen) {
+		return &ExitError{Code: ExitCodeError}
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeEnv(stdout, *shell, subscription, false)
+}
+
+// errNothingChosen is returned when the picker is quit without a selection
+var errNothingChosen = errors.New("no subscription chosen")
+
+// chooseEnvSubscription resolves the subscription for 'env' from the active
+// one, a query, or the TUI
+func chooseEnvSubscription(config *Config, provider SubscriptionProvider, query string, current bool, tty io.Writer) (Subscription, error) {
+	if current {
+		return provider.Current()
+	}
+
+	var matches []Subscription
+	if query != "" {
+		subscriptions, err := provider.List()
+		if err != nil {
+			return Subscription{}, fmt.Errorf("failed to fetch subscription data: %w", err)
+		}
+
+		var subscription Subscription
+		subscription, matches, err = resolveSubscription(subscriptions, query)
+		if err != nil || len(matches) == 1 {
+			return subscription, err
+		}
+	}
+
+	useRenderer(tty)
+	app := newUserApp(config, provider)
+	app.envMode = true
+	app.initialMatches = subscriptionIDs(matches)
+
+	if err := runTUI(app, tea.WithOutput(tty)); err != nil {
+		return Subscription{}, err
+	}
+	if app.envSubscription == nil {
+		return Subscription{}, errNothingChosen
+	}
+
+	return *app.envSubscription, nil
+}
+
+// chooseEnv records the highlighted subscription for export and quits the TUI
+func (app *App) chooseEnv() tea.Cmd {
+	sub, ok := app.list.SelectedItem().(Subscription)
+	if !ok {
+		return nil
+	}
+
+	app.envSubscription = &sub
+	return tea.Quit
+}
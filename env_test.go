@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestWriteEnv(t *testing.T) {
+	sub := Subscription{ID: "sub-1", TenantID: "tenant-1"}
+	sub.User.Name = "o'brien@example.com"
+
+	tests := []struct {
+		shell    string
+		unset    bool
+		contains []string
+	}{
+		{shell: ShellPosix, contains: []string{"export ARM_SUBSCRIPTION_ID='sub-1'\n", `export ASUBSELECT_USER='o'\''brien@example.com'`}},
+		{shell: PromptZsh, unset: true, contains: []string{"unset AZURE_TENANT_ID\n"}},
+		{shell: ShellFish, contains: []string{"set -gx AZURE_SUBSCRIPTION_ID 'sub-1';\n"}},
+		{shell: ShellFish, unset: true, contains: []string{"set -e ARM_TENANT_ID;\n"}},
+		{shell: ShellPowerShell, contains: []string{"$Env:AZURE_TENANT_ID = 'tenant-1'\n", "'o''brien@example.com'"}},
+		{shell: ShellPowerShell, unset: true, contains: []string{"Remove-Item Env:ARM_SUBSCRIPTION_ID -ErrorAction SilentlyContinue\n"}},
+		{shell: ShellDotenv, contains: []string{"ARM_TENANT_ID=\"tenant-1\"\n", `ASUBSELECT_USER="o'brien@example.com"`}},
+		{shell: ShellDotenv, unset: true, contains: []string{"AZURE_SUBSCRIPTION_ID=\n"}},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := writeEnv(&out, tt.shell, sub, tt.unset); err != nil {
+			t.Fatalf("Expected no error for %s, got: %v", tt.shell, err)
+		}
+
+		if lines := strings.Count(out.String(), "\n"); lines != 5 {
+			t.Errorf("Expected 5 lines for %s, got %d", tt.shell, lines)
+		}
+
+		for _, expected := range tt.contains {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("Expected %s output to contain %q, got: %s", tt.shell, expected, out.String())
+			}
+		}
+	}
+
+	if err := writeEnv(io.Discard, "tcsh", sub, false); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage, got %v", err)
+	}
+}
+
+func TestDotenvQuote(t *testing.T) {
+	tests := map[string]string{
+		"tenant-1":         `"tenant-1"`,
+		"Jane Doe # admin": `"Jane Doe # admin"`,
+		`say "hi" \o/`:     `"say \"hi\" \\o/"`,
+		"two\nlines":       `"two\nlines"`,
+	}
+
+	for value, expected := range tests {
+		if got := dotenvQuote(value); got != expected {
+			t.Errorf("Expected %s for %q, got %s", expected, value, got)
+		}
+	}
+}
+
+func TestDetectShell(t *testing.T) {
+	tests := map[string]string{
+		"/usr/bin/fish": ShellFish,
+		"/usr/bin/pwsh": ShellPowerShell,
+		"/bin/bash":     ShellPosix,
+		"":              ShellPosix,
+	}
+
+	for shell, expected := range tests {
+		t.Setenv("SHELL", shell)
+		if got := detectShell(); got != expected {
+			t.Errorf("Expected %s for SHELL=%q, got %s", expected, shell, got)
+		}
+	}
+}
+
+func TestRunEnv(t *testing.T) {
+	var out bytes.Buffer
+	if err := runEnv(DefaultConfig(), NewSampleProvider(), []string{"--shell", ShellDotenv, "the sub"}, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "ARM_SUBSCRIPTION_ID=\"8e9f0a1b-2c3d-4e5f-6a7b-8c9d0e1f2a3b\"\n") {
+		t.Errorf("Expected exports for 'the Sub', got: %s", out.String())
+	}
+
+	out.Reset()
+	if err := runEnv(DefaultConfig(), NewSampleProvider(), []string{"--shell", ShellPosix, "--current"}, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "export AZURE_SUBSCRIPTION_ID='3f50c9e1-8f3b-4d1e-9a2b-5b8e4b4e5f1a'") {
+		t.Errorf("Expected exports for the active subscription, got: %s", out.String())
+	}
+
+	out.Reset()
+	if err := runEnv(DefaultConfig(), NewFakeProvider(nil), []string{"--shell", ShellPosix, "--unset"}, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !strings.HasPrefix(out.String(), "unset AZURE_SUBSCRIPTION_ID\n") {
+		t.Errorf("Expected unset statements, got: %s", out.String())
+	}
+
+	if err := runEnv(DefaultConfig(), NewSampleProvider(), []string{"--shell", "tcsh", "the sub"}, io.Discard, io.Discard); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage, got %v", err)
+	}
+}
+
+func TestApp_EnvKey(t *testing.T) {
+	provider := NewSampleProvider()
+	app := NewApp(provider)
+	app.Update(app.loadSubscriptions())
+	app.Update(tea.KeyMsg{Type: tea.KeyDown})
+
+	_, cmd := app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyEnv)})
+	if cmd == nil || app.envSubscription == nil || app.envSubscription.Name != "Another Subscription" {
+		t.Fatalf("Expected highlighted subscription to be chosen for export, got %v", app.envSubscription)
+	}
+
+	if current, _ := provider.Current(); current.Name != "Subscription 1" {
+		t.Errorf("Expected the default subscription to be unchanged, got '%s'", current.Name)
+	}
+}
+
+func TestApp_EnvMode_Enter(t *testing.T) {
+	app := NewApp(NewSampleProvider())
+	app.envMode = true
+	app.Update(app.loadSubscriptions())
+
+	app.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if app.envSubscription == nil || app.state != StateSelectingSubscription {
+		t.Errorf("Expected enter to choose for export without switching, got state %v", app.state)
+	}
+}
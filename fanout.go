@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Fan-out configuration
+const (
+	CommandFanout = "fanout"
+
+	DefaultFanoutParallelism = 4
+
+	ReportText = "text"
+	ReportJSON = "json"
+
+	// KeyToggle toggles the highlighted subscription in fan-out mode
+	KeyToggle = " "
+
+	FanoutTitle       = "Select subscriptions to run against"
+	FanoutDoneMessage = "Done - press %s or '%s' to exit"
+
+	// FanoutWaitDelay bounds how long a cancelled command may keep its
+	// output open, e.g. through a child process that ignores the signal
+	FanoutWaitDelay = 5 * time.Second
+
+	// FanoutMaxOutput is how much output is kept per subscription; earlier
+	// output is dropped
+	FanoutMaxOutput = 1 << 20
+	// FanoutOutputLines is how many of the last output lines are shown per
+	// subscription in the TUI. The report has all that was kept.
+	FanoutOutputLines = 5
+
+	FanoutTruncatedMessage = "[%d bytes of earlier output omitted]\n"
+)
+
+// FanoutStatus is the progress of a single fan-out job
+type FanoutStatus int
+
+const (
+	FanoutQueued FanoutStatus = iota
+	FanoutRunning
+	FanoutSucceeded
+	FanoutFailed
+)
+
+// String implements fmt.Stringer
+func (s FanoutStatus) String() string {
+	switch s {
+	case FanoutQueued:
+		return "queued"
+	case FanoutRunning:
+		return "running"
+	case FanoutSucceeded:
+		return "succeeded"
+	case FanoutFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// FanoutResult is the outcome of running the command against one subscription
+type FanoutResult struct {
+	Subscription Subscription
+	Status       FanoutStatus
+	ExitCode     int
+	Output       string
+	Err          error
+	Duration     time.Duration
+}
+
+// FanoutProgressMsg is sent when a fan-out job changes status
+type FanoutProgressMsg struct {
+	Index  int
+	Result FanoutResult
+}
+
+// FanoutFinishedMsg is sent when every fan-out job has completed
+type FanoutFinishedMsg struct{}
+
+// FanoutRunner runs a command against several subscriptions with bounded
+// concurrency. Each job gets its own Session, so commands that rely on the
+// az default subscription see the right one.
+type FanoutRunner struct {
+	command   []string
+	parallel  int
+	sharedDir string
+	waitDelay time.Duration
+
+	mu      sync.Mutex
+	results []FanoutResult
+	events  chan tea.Msg
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewFanoutRunner creates a runner for command, sharing the token cache in sharedDir
+func NewFanoutRunner(sharedDir string, command []string, parallel int) *FanoutRunner {
+	return &FanoutRunner{
+		command:   command,
+		parallel:  max(parallel, 1),
+		sharedDir: sharedDir,
+		waitDelay: FanoutWaitDelay,
+	}
+}
+
+// Start runs the command against subscriptions in the background and returns
+// a command delivering the first progress message
+func (r *FanoutRunner) Start(subscriptions []Subscription) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r.mu.Lock()
+	r.results = make([]FanoutResult, len(subscriptions))
+	for i, sub := range subscriptions {
+		r.results[i] = FanoutResult{Subscription: sub, Status: FanoutQueued}
+	}
+	r.mu.Unlock()
+
+	// Buffered so jobs never block once the TUI has stopped listening
+	r.events = make(chan tea.Msg, 2*len(subscriptions))
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		defer close(r.events)
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, r.parallel)
+		for i, sub := range subscriptions {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+
+				r.update(i, FanoutResult{Subscription: sub, Status: FanoutRunning})
+				r.update(i, r.runJob(ctx, sub))
+			}()
+		}
+		wg.Wait()
+	}()
+
+	return r.wait
+}
+
+// wait blocks until the next progress message
+func (r *FanoutRunner) wait() tea.Msg {
+	msg, ok := <-r.events
+	if !ok {
+		return FanoutFinishedMsg{}
+	}
+	return msg
+}
+
+// Stop cancels outstanding jobs and waits for them to exit
+func (r *FanoutRunner) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	<-r.done
+}
+
+// Results returns a snapshot of every job's result
+func (r *FanoutRunner) Results() []FanoutResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]FanoutResult(nil), r.results...)
+}
+
+// update records a job result and notifies the TUI
+func (r *FanoutRunner) update(index int, result FanoutResult) {
+	r.mu.Lock()
+	r.results[index] = result
+	r.mu.Unlock()
+
+	r.events <- FanoutProgressMsg{Index: index, Result: result}
+}
+
+// runJob runs the command against one subscription in its own session
+func (r *FanoutRunner) runJob(ctx context.Context, subscription Subscription) FanoutResult {
+	result := FanoutResult{Subscription: subscription, Status: FanoutFailed, ExitCode: -1}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	session, err := NewSession(r.sharedDir)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer session.Close()
+
+	if _, err := switchSubscription(session.Provider(), subscription, ""); err != nil {
+		result.Err = err
+		return result
+	}
+
+	cmd := exec.CommandContext(ctx, r.command[0], r.command[1:]...)
+	cmd.Env = append(session.Environ(), subscriptionEnviron(subscription)...)
+	cmd.WaitDelay = r.waitDelay
+	output := &tailBuffer{max: FanoutMaxOutput}
+	cmd.Stdout, cmd.Stderr = output, output
+	err = cmd.Run()
+	result.Output = output.String()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.Status, result.ExitCode = FanoutSucceeded, 0
+	case errors.As(err, &exitErr):
+		result.ExitCode = childExitCode(exitErr)
+	default:
+		result.Err = err
+	}
+
+	return result
+}
+
+// tailBuffer is an io.Writer keeping the last max bytes written to it
+type tailBuffer struct {
+	max     int
+	data    []byte
+	dropped int
+}
+
+// Write implements io.Writer
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if excess := len(b.data) - b.max; excess > 0 {
+		b.data = b.data[excess:]
+		b.dropped += excess
+	}
+	return len(p), nil
+}
+
+// String returns the kept output from the first whole line, noting how much
+// was dropped before it
+func (b *tailBuffer) String() string {
+	if b.dropped == 0 {
+		return string(b.data)
+	}
+
+	data, dropped := b.data, b.dropped
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data, dropped = data[i+1:], dropped+i+1
+	}
+	return fmt.Sprintf(FanoutTruncatedMessage, dropped) + string(data)
+}
+
+// runFanout implements 'asubselect fanout -- <command>'. Subscriptions are
+// chosen in the TUI, and a report is written once the TUI exits.
+func runFanout(config *Config, provider SubscriptionProvider, args []string, stdout, stderr io.Writer) error {
+	flags := newCommandFlags(CommandFanout, stderr)
+	parallel := flags.Int("parallel", DefaultFanoutParallelism, "maximum number of concurrent commands")
+	report := flags.String("report", ReportText, "report format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	command := flags.Args()
+	if len(command) == 0 {
+		command = strings.Fields(config.FanoutCommand)
+	}
+	if len(command) == 0 {
+		return fmt.Errorf("%w: %s %s [flags] -- <command> [args...], or set fanout_command", ErrUsage, AppName, CommandFanout)
+	}
+	if *report != ReportText && *report != ReportJSON {
+		return fmt.Errorf("%w: unknown report format %q", ErrUsage, *report)
+	}
+
+	sharedDir, err := azureConfigDir()
+	if err != nil {
+		return err
+	}
+
+	app := newUserApp(config, provider)
+	app.enableFanout(NewFanoutRunner(sharedDir, command, *parallel))
+	if err := runTUI(app); err != nil {
+		return err
+	}
+	app.fanout.Stop()
+
+	results := app.fanout.Results()
+	if len(results) == 0 {
+		return nil
+	}
+
+	if err := writeFanoutReport(stdout, *report, results); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		if result.Status != FanoutSucceeded {
+			return &ExitError{Code: ExitCodeError}
+		}
+	}
+	return nil
+}
+
+// fanoutReportEntry is the JSON form of a FanoutResult
+type fanoutReportEntry struct {
+	SubscriptionID string `json:"subscriptionId"`
+	Name           string `json:"name"`
+	Tenant         string `json:"tenant"`
+	Status         string `json:"status"`
+	ExitCode       int    `json:"exitCode"`
+	DurationMs     int64  `json:"durationMs"`
+	Output         string `json:"output"`
+	Error          string `json:"error,omitempty"`
+}
+
+// writeFanoutReport writes the aggregated results in the given format
+func writeFanoutReport(out io.Writer, format string, results []FanoutResult) error {
+	if format == ReportJSON {
+		entries := make([]fanoutReportEntry, len(results))
+		for i, result := range results {
+			entries[i] = fanoutReportEntry{
+				SubscriptionID: result.Subscription.ID,
+				Name:           result.Subscription.Name,
+				Tenant:         result.Subscription.TenantDisplayName,
+				Status:         result.Status.String(),
+				ExitCode:       result.ExitCode,
+				DurationMs:     result.Duration.Milliseconds(),
+				Output:         result.Output,
+			}
+			if result.Err != nil {
+				entries[i].Error = result.Err.Error()
+			}
+		}
+
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Status == FanoutSucceeded {
+			succeeded++
+		}
+
+		fmt.Fprintf(out, "==> %s (%s): %s\n", result.Subscription.Title(), result.Subscription.ID, fanoutStatusText(result))
+		if result.Output != "" {
+			fmt.Fprint(out, result.Output)
+			if !strings.HasSuffix(result.Output, "\n") {
+				fmt.Fprintln(out)
+			}
+		}
+	}
+	fmt.Fprintf(out, "\n%d/%d succeeded\n", succeeded, len(results))
+
+	return nil
+}
+
+// fanoutStatusText describes a result's status for display
+func fanoutStatusText(result FanoutResult) string {
+	switch {
+	case result.Err != nil:
+		return fmt.Sprintf("%s: %v", result.Status, result.Err)
+	case result.Status == FanoutSucceeded || result.Status == FanoutFailed:
+		return fmt.Sprintf("exit %d in %s", result.ExitCode, result.Duration.Round(time.Millisecond))
+	default:
+		return result.Status.String()
+	}
+}
+
+// enableFanout switches the list to multi-select mode for runner
+func (app *App) enableFanout(runner *FanoutRunner) {
+	app.fanout = runner
+	app.selected = make(map[string]bool)
+	app.updateListTitle()
+}
+
+// toggleSelected toggles the highlighted subscription, or all of a
+// highlighted tenant's subscriptions
+func (app *App) toggleSelected() {
+	switch item := app.list.SelectedItem().(type) {
+	case Subscription:
+		if app.selected[item.ID] {
+			delete(app.selected, item.ID)
+		} else {
+			app.selected[item.ID] = true
+		}
+	case tenantHeader:
+		// Selects the whole tenant, or clears it if all are selected
+		var ids []string
+		all := true
+		for _, sub := range app.subscriptions {
+			if sub.TenantID == item.tenantID {
+				ids = append(ids, sub.ID)
+				all = all && app.selected[sub.ID]
+			}
+		}
+		for _, id := range ids {
+			if all {
+				delete(app.selected, id)
+			} else {
+				app.selected[id] = true
+			}
+		}
+	}
+	app.updateListTitle()
+}
+
+// fanoutTargets returns the selected subscriptions in the order the list
+// shows them, counting those in collapsed groups
+func (app *App) fanoutTargets() []Subscription {
+	var shown []Subscription
+	if app.grouped {
+		for _, group := range app.groupByTenant(app.history.sortByRecency(app.tenantSubscriptions())) {
+			shown = append(shown, group.subscriptions...)
+		}
+	} else {
+		for _, item := range app.list.Items() {
+			if sub, ok := item.(Subscription); ok {
+				shown = append(shown, sub)
+			}
+		}
+	}
+	// Selections the list no longer shows, e.g. from another tenant, come last
+	shown = append(shown, app.subscriptions...)
+
+	var targets []Subscription
+	added := make(map[string]bool, len(app.selected))
+	for _, sub := range shown {
+		if app.selected[sub.ID] && !added[sub.ID] {
+			added[sub.ID] = true
+			targets = append(targets, sub)
+		}
+	}
+	return targets
+}
+
+// startFanout runs the command against the selected subscriptions, or the
+// highlighted one if nothing is selected
+func (app *App) startFanout() tea.Cmd {
+	targets := app.fanoutTargets()
+	if len(targets) == 0 {
+		sub, ok := app.list.SelectedItem().(Subscription)
+		if !ok {
+			return nil
+		}
+		targets = []Subscription{sub}
+	}
+
+	app.fanoutResults = make([]FanoutResult, len(targets))
+	for i, sub := range targets {
+		app.fanoutResults[i] = FanoutResult{Subscription: sub, Status: FanoutQueued}
+	}
+	app.fanoutDone = false
+	app.fanoutOutput = viewport.New(0, 0)
+	app.resizeFanoutOutput()
+	app.state = StateRunningFanout
+
+	return tea.Batch(app.spinner.Tick, app.fanout.Start(targets))
+}
+
+// handleFanoutProgress records a job update and waits for the next one
+func (app *App) handleFanoutProgress(msg FanoutProgressMsg) (tea.Model, tea.Cmd) {
+	if msg.Index < len(app.fanoutResults) {
+		app.fanoutResults[msg.Index] = msg.Result
+	}
+	return app, app.fanout.wait
+}
+
+// handleFanoutFinished marks the fan-out as complete
+func (app *App) handleFanoutFinished(msg FanoutFinishedMsg) (tea.Model, tea.Cmd) {
+	app.fanoutDone = true
+	return app, nil
+}
+
+// resizeFanoutOutput fits the fan-out progress between its header and footer
+func (app *App) resizeFanoutOutput() {
+	h, v := docStyle.GetFrameSize()
+	app.fanoutOutput.Width = max(width-h, 0)
+	// The header and footer, each separated by a blank line
+	app.fanoutOutput.Height = max(height-v-4, 1)
+}
+
+// fanoutView renders live per-subscription progress, scrolling when it does
+// not fit
+func (app *App) fanoutView() string {
+	completed := 0
+	rows := make([]string, 0, len(app.fanoutResults))
+	outputStyle := lipgloss.NewStyle().Foreground(app.theme.Subtext).PaddingLeft(2)
+	for _, result := range app.fanoutResults {
+		var icon string
+		color := app.theme.Text
+		switch result.Status {
+		case FanoutQueued:
+			icon, color = "·", app.theme.Muted
+		case FanoutRunning:
+			icon = app.spinner.View()
+		case FanoutSucceeded:
+			icon, color = "✓", app.theme.Success
+			completed++
+		case FanoutFailed:
+			icon, color = "✗", app.theme.Error
+			completed++
+		}
+
+		rows = append(rows, lipgloss.NewStyle().Foreground(color).Render(
+			fmt.Sprintf("%s %s  %s", icon, result.Subscription.Title(), fanoutStatusText(result))))
+		if output := lastLines(result.Output, FanoutOutputLines); output != "" {
+			rows = append(rows, outputStyle.Render(output))
+		}
+	}
+	app.fanoutOutput.SetContent(strings.Join(rows, "\n"))
+
+	header := fmt.Sprintf("Running '%s' (%d/%d)", strings.Join(app.fanout.command, " "), completed, len(app.fanoutResults))
+	footer := ""
+	if app.fanoutDone {
+		footer = fmt.Sprintf(FanoutDoneMessage, keyName(app.config.Keys.Select), keyName(app.config.Keys.Quit))
+	}
+
+	content := lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.NewStyle().Foreground(app.theme.Accent).Bold(true).Render(header),
+		"",
+		app.fanoutOutput.View(),
+		"",
+		lipgloss.NewStyle().Foreground(app.theme.Subtext).Render(footer),
+	)
+
+	return docStyle.Render(content)
+}
+
+// lastLines returns the last n lines of output, without the final newline
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	return strings.Join(lines[max(len(lines)-n, 0):], "\n")
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFanoutRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	runner := NewFanoutRunner(newSharedConfigDir(t), []string{"sh", "-c", `echo "$ARM_SUBSCRIPTION_ID"; [ "$ARM_TENANT_ID" = tenant-1 ]`}, 2)
+	cmd := runner.Start([]Subscription{
+		{ID: "sub-1", TenantID: "tenant-1"},
+		{ID: "sub-2", TenantID: "tenant-2"},
+	})
+
+	for {
+		if _, done := cmd().(FanoutFinishedMsg); done {
+			break
+		}
+	}
+	runner.Stop()
+
+	results := runner.Results()
+	if results[0].Status != FanoutSucceeded || results[0].Output != "sub-1\n" {
+		t.Errorf("Expected sub-1 to succeed, got %+v", results[0])
+	}
+
+	if results[1].Status != FanoutFailed || results[1].ExitCode != 1 || results[1].Output != "sub-2\n" {
+		t.Errorf("Expected sub-2 to fail with exit code 1, got %+v", results[1])
+	}
+}
+
+func TestFanoutRunner_Stop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	// The background sleep keeps the output open after sh is killed
+	runner := NewFanoutRunner(newSharedConfigDir(t), []string{"sh", "-c", "sleep 30 & wait"}, 1)
+	runner.waitDelay = 100 * time.Millisecond
+	cmd := runner.Start([]Subscription{{ID: "sub-1", TenantID: "tenant-1"}})
+	if msg, ok := cmd().(FanoutProgressMsg); !ok || msg.Result.Status != FanoutRunning {
+		t.Fatalf("Expected the job to start, got %#v", msg)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	start := time.Now()
+	runner.Stop()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected Stop to return promptly, took %v", elapsed)
+	}
+	if result := runner.Results()[0]; result.Status != FanoutFailed {
+		t.Errorf("Expected the stopped job to fail, got %+v", result)
+	}
+}
+
+func TestFanoutRunner_SessionError(t *testing.T) {
+	runner := NewFanoutRunner(t.TempDir(), []string{"true"}, 1)
+	cmd := runner.Start([]Subscription{{ID: "sub-1"}})
+
+	for {
+		if _, done := cmd().(FanoutFinishedMsg); done {
+			break
+		}
+	}
+
+	if result := runner.Results()[0]; result.Status != FanoutFailed || !errors.Is(result.Err, ErrProfileNotFound) {
+		t.Errorf("Expected job to fail without a profile, got %+v", result)
+	}
+}
+
+func TestApp_FanoutSelection(t *testing.T) {
+	app := NewApp(NewSampleProvider())
+	app.enableFanout(NewFanoutRunner(t.TempDir(), []string{"true"}, 1))
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+
+	space := tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+	app.Update(space)
+	app.Update(tea.KeyMsg{Type: tea.KeyDown})
+	app.Update(space)
+	app.Update(space)
+	app.Update(tea.KeyMsg{Type: tea.KeyDown})
+	app.Update(space)
+
+	if len(app.selected) != 2 {
+		t.Fatalf("Expected 2 selected subscriptions, got %d", len(app.selected))
+	}
+
+	if !strings.Contains(app.list.Title, "(2 selected)") {
+		t.Errorf("Expected selection counter in title, got '%s'", app.list.Title)
+	}
+
+	if !strings.Contains(app.View(), "[x] ") {
+		t.Error("Expected selected items to be marked")
+	}
+
+	_, cmd := app.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if app.state != StateRunningFanout || len(app.fanoutResults) != 2 || cmd == nil {
+		t.Fatalf("Expected fan-out to start for 2 subscriptions, got state %v", app.state)
+	}
+
+	if app.fanoutResults[0].Subscription.Name != "Subscription 1" || app.fanoutResults[1].Subscription.Name != "Yet Another Subscription" {
+		t.Errorf("Expected selections in list order, got %+v", app.fanoutResults)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); !app.fanoutDone && time.Now().Before(deadline); {
+		app.Update(app.fanout.wait())
+	}
+
+	if !app.fanoutDone || !strings.Contains(app.View(), "Done - press enter or 'q' to exit") {
+		t.Error("Expected fan-out to finish")
+	}
+}
+
+func TestApp_FanoutTargets(t *testing.T) {
+	app := NewApp(NewFakeProvider(groupTestSubscriptions))
+	app.enableFanout(NewFanoutRunner(t.TempDir(), []string{"true"}, 1))
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+	app.favorites.Toggle("sub-3")
+	app.setListItems("sub-1")
+	app.selected["sub-1"], app.selected["sub-3"] = true, true
+
+	if names := subscriptionNames(app.fanoutTargets()); names != "Test,Prod" {
+		t.Errorf("Expected the pinned subscription first, got %s", names)
+	}
+
+	// Collapsed groups keep their place
+	app.grouped = true
+	app.collapsed["t-1"] = true
+	app.selected["sub-2"], app.selected["sub-4"] = true, true
+	app.setListItems("sub-1")
+	if names := subscriptionNames(app.fanoutTargets()); names != "Dev,Sandbox,Test,Prod" {
+		t.Errorf("Expected targets in tenant order, got %s", names)
+	}
+}
+
+// subscriptionNames joins the subscriptions' names
+func subscriptionNames(subscriptions []Subscription) string {
+	names := make([]string, len(subscriptions))
+	for i, sub := range subscriptions {
+		names[i] = sub.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestTailBuffer(t *testing.T) {
+	output := &tailBuffer{max: 10}
+	fmt.Fprint(output, "short\n")
+	if output.String() != "short\n" {
+		t.Errorf("Expected output kept whole, got %q", output.String())
+	}
+
+	fmt.Fprint(output, "line 2\nline 3\n")
+	if expected := fmt.Sprintf(FanoutTruncatedMessage, 13) + "line 3\n"; output.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, output.String())
+	}
+	if len(output.data) != 10 {
+		t.Errorf("Expected 10 bytes kept, got %d", len(output.data))
+	}
+}
+
+func TestApp_FanoutScrolls(t *testing.T) {
+	app := NewApp(NewSampleProvider())
+	app.enableFanout(NewFanoutRunner(t.TempDir(), []string{"true"}, 1))
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 12})
+	app.Update(app.loadSubscriptions())
+	for _, sub := range app.subscriptions {
+		app.selected[sub.ID] = true
+	}
+	app.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	app.fanout.Stop()
+
+	for i := range app.fanoutResults {
+		app.fanoutResults[i].Status = FanoutSucceeded
+		app.fanoutResults[i].Output = "line 1\nline 2\nline 3\nline 4\nline 5\nline 6\n"
+	}
+	app.fanoutDone = true
+
+	view := app.View()
+	if lines := strings.Count(view, "\n") + 1; lines > 12 {
+		t.Errorf("Expected the view to fit 12 lines, got %d:\n%s", lines, view)
+	}
+	if !strings.Contains(view, "line 6") || strings.Contains(view, "line 1") {
+		t.Errorf("Expected the last output lines, got:\n%s", view)
+	}
+
+	first := app.fanoutResults[0].Subscription.Title()
+	app.Update(tea.KeyMsg{Type: tea.KeyPgDown})
+	if strings.Contains(app.View(), first) {
+		t.Errorf("Expected to scroll past %s", first)
+	}
+}
+
+func TestWriteFanoutReport(t *testing.T) {
+	results := []FanoutResult{
+		{Subscription: Subscription{ID: "sub-1", Name: "Sub 1"}, Status: FanoutSucceeded, Output: "ok"},
+		{Subscription: Subscription{ID: "sub-2", Name: "Sub 2"}, Status: FanoutFailed, ExitCode: 2, Output: "boom\n"},
+	}
+
+	var text bytes.Buffer
+	if err := writeFanoutReport(&text, ReportText, results); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !strings.Contains(text.String(), "==> Sub 2 (sub-2): exit 2") || !strings.HasSuffix(text.String(), "1/2 succeeded\n") {
+		t.Errorf("Unexpected text report: %s", text.String())
+	}
+
+	var out bytes.Buffer
+	if err := writeFanoutReport(&out, ReportJSON, results); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var entries []fanoutReportEntry
+	if err := json.Unmarshal(out.Bytes(), &entries); err != nil {
+		t.Fatalf("Expected valid JSON, got: %v", err)
+	}
+
+	if len(entries) != 2 || entries[1].Status != "failed" || entries[1].ExitCode != 2 {
+		t.Errorf("Unexpected JSON report: %+v", entries)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Favorites configuration
+const (
+	FavoritesFileName = "favorites.json"
+
+	// KeyFavorite stars or unstars the highlighted subscription
+	KeyFavorite = "s"
+
+	FavoriteMarker      = "★ "
+	PinnedSectionTitle  = "Pinned"
+	OthersSectionTitle  = "All subscriptions"
+	favoritesFileFormat = 1
+)
+
+// Favorites is the persisted set of starred subscription IDs
+type Favorites struct {
+	path string
+	ids  []string
+}
+
+// favoritesFile is the on-disk form of Favorites
+type favoritesFile struct {
+	Version   int      `json:"version"`
+	Favorites []string `json:"favorites"`
+}
+
+// defaultFavoritesPath returns the favorites file in the config directory
+func defaultFavoritesPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FavoritesFileName), nil
+}
+
+// LoadFavorites reads favorites from path. A missing file yields an empty
+// set, and an empty path yields a set that is never saved.
+func LoadFavorites(path string) (*Favorites, error) {
+	favorites := &Favorites{path: path}
+	if path == "" {
+		return favorites, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return favorites, nil
+	}
+	if err != nil {
+		return favorites, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var file favoritesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return favorites, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	favorites.ids = file.Favorites
+
+	return favorites, nil
+}
+
+// Contains reports whether the subscription ID is a favorite
+func (f *Favorites) Contains(id string) bool {
+	return slices.Contains(f.ids, id)
+}
+
+// Len returns the number of favorites
+func (f *Favorites) Len() int {
+	return len(f.ids)
+}
+
+// Toggle stars or unstars the subscription ID, reporting whether it is now a favorite
+func (f *Favorites) Toggle(id string) bool {
+	if index := slices.Index(f.ids, id); index >= 0 {
+		f.ids = slices.Delete(f.ids, index, index+1)
+		return false
+	}
+
+	f.ids = append(f.ids, id)
+	return true
+}
+
+// Save writes the favorites to disk
+func (f *Favorites) Save() error {
+	if f.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(favoritesFile{Version: favoritesFileFormat, Favorites: f.ids}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode favorites: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(f.path), err)
+	}
+
+	return writeFileAtomic(f.path, append(data, '\n'))
+}
+
+// sectionHeader is a non-selectable row introducing a group of subscriptions.
+// Its empty FilterValue keeps it out of filtered results.
+type sectionHeader struct {
+	title string
+}
+
+// FilterValue implements list.Item interface
+func (h sectionHeader) FilterValue() string {
+	return ""
+}
+
+// renderSectionHeader draws a section header in place of a list row
+func renderSectionHeader(header sectionHeader, theme Theme) string {
+	return lipgloss.NewStyle().
+		Foreground(theme.Header).
+		Bold(true).
+		PaddingLeft(2).
+		Render(header.title)
+}
+
+// orderedItems returns the list items for subscriptions, most recently used
+// first, with favorites first under a "Pinned" header when there are any, or
+// grouped by tenant in the grouped view
+func (app *App) orderedItems() []list.Item {
+	subscriptions := app.history.sortByRecency(app.tenantSubscriptions())
+	if app.grouped {
+		return app.groupedItems(subscriptions)
+	}
+
+	var pinned, others []list.Item
+	for _, sub := range subscriptions {
+		if app.favorites.Contains(sub.ID) {
+			pinned = append(pinned, sub)
+		} else {
+			others = append(others, sub)
+		}
+	}
+
+	if len(pinned) == 0 {
+		return others
+	}
+
+	items := make([]list.Item, 0, len(app.subscriptions)+2)
+	items = append(items, sectionHeader{title: PinnedSectionTitle})
+	items = append(items, pinned...)
+	if len(others) > 0 {
+		items = append(items, sectionHeader{title: OthersSectionTitle})
+		items = append(items, others...)
+	}
+
+	return items
+}
+
+// setListItems rebuilds the list and highlights the subscription with the given ID
+func (app *App) setListItems(highlightID string) tea.Cmd {
+	return app.setListItemsAt(func(item list.Item) bool {
+		sub, ok := item.(Subscription)
+		return ok && sub.ID == highlightID
+	})
+}
+
+// setListItemsAt rebuilds the list and highlights the first matching item
+func (app *App) setListItemsAt(highlight func(list.Item) bool) tea.Cmd {
+	cmd := app.list.SetItems(app.orderedItems())
+
+	if index := slices.IndexFunc(app.list.VisibleItems(), highlight); index >= 0 {
+		app.list.Select(index)
+	}
+	app.skipSectionHeader(0)
+
+	return cmd
+}
+
+// skipSectionHeader moves the cursor off a section header, continuing in the
+// direction it was moving, or downwards if unknown
+func (app *App) skipSectionHeader(previousIndex int) {
+	if _, ok := app.list.SelectedItem().(sectionHeader); !ok {
+		return
+	}
+
+	visible := len(app.list.VisibleItems())
+	index := app.list.Index()
+	if index < previousIndex && index > 0 || index == visible-1 {
+		app.list.CursorUp()
+	} else {
+		app.list.CursorDown()
+	}
+}
+
+// toggleFavorite stars or unstars the highlighted subscription
+func (app *App) toggleFavorite() tea.Cmd {
+	sub, ok := app.list.SelectedItem().(Subscription)
+	if !ok {
+		return nil
+	}
+
+	starred := app.favorites.Toggle(sub.ID)
+	cmd := app.setListItems(sub.ID)
+
+	status := "Unpinned " + sub.Name
+	if starred {
+		status = "Pinned " + sub.Name
+	}
+	if err := app.favorites.Save(); err != nil {
+		status = fmt.Sprintf("Failed to save favorites: %v", err)
+	}
+
+	return tea.Batch(cmd, app.list.NewStatusMessage(status))
+}
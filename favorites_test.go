@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFavorites_LoadToggleSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", FavoritesFileName)
+
+	favorites, err := LoadFavorites(path)
+	if err != nil {
+		t.Fatalf("Expected missing file to load empty favorites, got: %v", err)
+	}
+	if favorites.Len() != 0 {
+		t.Fatalf("Expected no favorites, got %d", favorites.Len())
+	}
+
+	if !favorites.Toggle("sub-1") || !favorites.Toggle("sub-2") || favorites.Toggle("sub-1") {
+		t.Fatal("Expected Toggle to report the new favorite state")
+	}
+	if err := favorites.Save(); err != nil {
+		t.Fatalf("Expected no error saving, got: %v", err)
+	}
+
+	reloaded, err := LoadFavorites(path)
+	if err != nil {
+		t.Fatalf("Expected no error reloading, got: %v", err)
+	}
+	if reloaded.Len() != 1 || !reloaded.Contains("sub-2") || reloaded.Contains("sub-1") {
+		t.Errorf("Expected only sub-2 after reload, got %v", reloaded.ids)
+	}
+}
+
+func TestLoadFavorites_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FavoritesFileName)
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	favorites, err := LoadFavorites(path)
+	if err == nil {
+		t.Fatal("Expected an error for invalid favorites")
+	}
+	if favorites == nil || favorites.Len() != 0 {
+		t.Error("Expected empty favorites alongside the error")
+	}
+}
+
+func TestApp_ToggleFavorite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FavoritesFileName)
+	favorites, err := LoadFavorites(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewApp(NewSampleProvider())
+	app.favorites = favorites
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+
+	// Pin "Another Subscription", the second in the sample data
+	app.Update(tea.KeyMsg{Type: tea.KeyDown})
+	app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyFavorite)})
+
+	items := app.list.Items()
+	if header, ok := items[0].(sectionHeader); !ok || header.title != PinnedSectionTitle {
+		t.Fatalf("Expected a %q header first, got %#v", PinnedSectionTitle, items[0])
+	}
+	if sub, ok := items[1].(Subscription); !ok || sub.Name != "Another Subscription" {
+		t.Errorf("Expected the favorite right after the header, got %#v", items[1])
+	}
+	if header, ok := items[2].(sectionHeader); !ok || header.title != OthersSectionTitle {
+		t.Errorf("Expected a %q header after the favorites, got %#v", OthersSectionTitle, items[2])
+	}
+
+	if sub, ok := app.list.SelectedItem().(Subscription); !ok || sub.Name != "Another Subscription" {
+		t.Errorf("Expected the pinned subscription to stay highlighted, got %#v", app.list.SelectedItem())
+	}
+	if !strings.Contains(app.View(), FavoriteMarker+"Contoso / Another Subscription") {
+		t.Error("Expected the favorite to be marked")
+	}
+
+	saved, err := LoadFavorites(path)
+	if err != nil || !saved.Contains("7d2e4c3a-6b9d-4f2e-8a3d-1c2e3b4d5f6a") {
+		t.Errorf("Expected the favorite to be persisted, got %v (%v)", saved, err)
+	}
+
+	// Moving up from the only favorite must not land on the header
+	app.Update(tea.KeyMsg{Type: tea.KeyUp})
+	if _, ok := app.list.SelectedItem().(Subscription); !ok {
+		t.Errorf("Expected the cursor to skip the header, got %#v", app.list.SelectedItem())
+	}
+	app.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if sub, ok := app.list.SelectedItem().(Subscription); !ok || sub.Name != "Subscription 1" {
+		t.Errorf("Expected the cursor to skip the header to the first other subscription, got %#v", app.list.SelectedItem())
+	}
+
+	app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyFavorite)})
+	app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyFavorite)})
+	if app.favorites.Len() != 1 {
+		t.Errorf("Expected toggling twice to leave one favorite, got %d", app.favorites.Len())
+	}
+}
+
+func TestApp_FilterExcludesSectionHeaders(t *testing.T) {
+	app := NewApp(NewSampleProvider())
+	app.favorites.Toggle("9a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d")
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+
+	app.list.SetFilterText("Subscription")
+	for _, item := range app.list.VisibleItems() {
+		if _, ok := item.(sectionHeader); ok {
+			t.Errorf("Expected no section headers in filtered results, got %#v", item)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// View modes for the subscription list
+const (
+	ViewFlat   = "flat"
+	ViewTenant = "tenant"
+)
+
+var viewModes = []string{ViewFlat, ViewTenant}
+
+// Grouped view configuration
+const (
+	// KeyGroup switches between the flat and grouped views
+	KeyGroup = "t"
+
+	ExpandedMarker  = "▾ "
+	CollapsedMarker = "▸ "
+	groupIndent     = "  "
+)
+
+// tenantHeader is a selectable row heading a tenant's subscriptions in the
+// grouped view. Like sectionHeader, it is left out of filtered results.
+type tenantHeader struct {
+	tenantID  string
+	name      string
+	count     int
+	collapsed bool
+}
+
+// FilterValue implements list.Item interface
+func (h tenantHeader) FilterValue() string {
+	return ""
+}
+
+// renderTenantHeader draws a tenant header with its subscription count
+func renderTenantHeader(header tenantHeader, selected bool, theme Theme) string {
+	marker := ExpandedMarker
+	if header.collapsed {
+		marker = CollapsedMarker
+	}
+
+	style := lipgloss.NewStyle().Foreground(theme.Header).Bold(true).PaddingLeft(2)
+	if selected {
+		style = style.
+			Foreground(theme.Accent).
+			Border(lipgloss.NormalBorder(), false, false, false, true).
+			BorderForeground(theme.ActiveBorder).
+			PaddingLeft(1)
+	}
+
+	count := lipgloss.NewStyle().Foreground(theme.Subtext).Render(fmt.Sprintf(" (%d)", header.count))
+	return style.Render(marker+header.name) + count
+}
+
+// tenantGroup is a tenant and its subscriptions, in list order
+type tenantGroup struct {
+	header        tenantHeader
+	subscriptions []Subscription
+}
+
+// groupByTenant groups subscriptions by tenant, ordered by tenant name.
+// Subscriptions keep their relative order, with favorites first.
+func (app *App) groupByTenant(subscriptions []Subscription) []tenantGroup {
+	var groups []tenantGroup
+	index := make(map[string]int)
+	for _, sub := range subscriptions {
+		i, ok := index[sub.TenantID]
+		if !ok {
+			i = len(groups)
+			index[sub.TenantID] = i
+			groups = append(groups, tenantGroup{header: tenantHeader{
+				tenantID:  sub.TenantID,
+				name:      cmp.Or(sub.TenantDisplayName, sub.TenantID, "Unknown tenant"),
+				collapsed: app.collapsed[sub.TenantID],
+			}})
+		}
+		groups[i].subscriptions = append(groups[i].subscriptions, sub)
+		groups[i].header.count++
+	}
+
+	slices.SortStableFunc(groups, func(a, b tenantGroup) int {
+		return strings.Compare(strings.ToLower(a.header.name), strings.ToLower(b.header.name))
+	})
+	for _, group := range groups {
+		slices.SortStableFunc(group.subscriptions, func(a, b Subscription) int {
+			return compareBool(app.favorites.Contains(b.ID), app.favorites.Contains(a.ID))
+		})
+	}
+
+	return groups
+}
+
+// compareBool orders false before true
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// groupedItems returns the list items for the grouped view. While filtering,
+// headers are left out and collapsed groups included, so every subscription
+// can be found.
+func (app *App) groupedItems(subscriptions []Subscription) []list.Item {
+	filtering := app.list.FilterState() != list.Unfiltered
+
+	items := make([]list.Item, 0, len(subscriptions)+8)
+	for _, group := range app.groupByTenant(subscriptions) {
+		if !filtering {
+			items = append(items, group.header)
+			if group.header.collapsed {
+				continue
+			}
+		}
+		for _, sub := range group.subscriptions {
+			items = append(items, sub)
+		}
+	}
+	return items
+}
+
+// isGrouped reports whether the list currently shows tenant groups
+func (app *App) isGrouped() bool {
+	return app.grouped && app.list.FilterState() == list.Unfiltered
+}
+
+// selectedTenant returns the tenant of the highlighted header or subscription
+func (app *App) selectedTenant() (string, bool) {
+	switch item := app.list.SelectedItem().(type) {
+	case tenantHeader:
+		return item.tenantID, true
+	case Subscription:
+		return item.TenantID, true
+	default:
+		return "", false
+	}
+}
+
+// setCollapsed collapses or expands the highlighted tenant, keeping the
+// cursor on its header
+func (app *App) setCollapsed(collapsed bool) tea.Cmd {
+	tenantID, ok := app.selectedTenant()
+	if !ok || app.collapsed[tenantID] == collapsed {
+		return nil
+	}
+
+	app.collapsed[tenantID] = collapsed
+	return app.setListItemsAt(func(item list.Item) bool {
+		header, ok := item.(tenantHeader)
+		return ok && header.tenantID == tenantID
+	})
+}
+
+// toggleGrouped switches between the flat and grouped views, keeping the
+// highlighted subscription
+func (app *App) toggleGrouped() tea.Cmd {
+	highlightID := app.selectedID
+	if sub, ok := app.list.SelectedItem().(Subscription); ok {
+		highlightID = sub.ID
+	}
+
+	app.grouped = !app.grouped
+	return app.setListItems(highlightID)
+}
+
+// handleGroupKey handles the view and group keys while the list has focus
+func (app *App) handleGroupKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	keys := app.keys
+
+	switch {
+	case key.Matches(msg, keys.Group):
+		return app.toggleGrouped(), true
+	case key.Matches(msg, keys.Collapse, keys.Expand) && !app.isGrouped():
+		// Without groups the keys page through the list
+		if key.Matches(msg, keys.Collapse) {
+			app.list.Paginator.PrevPage()
+		} else {
+			app.list.Paginator.NextPage()
+		}
+		return nil, true
+	case key.Matches(msg, keys.Collapse):
+		return app.setCollapsed(true), true
+	case key.Matches(msg, keys.Expand):
+		return app.setCollapsed(false), true
+	}
+
+	// Select on a header folds it, unless starting a fan-out
+	if header, ok := app.list.SelectedItem().(tenantHeader); ok && app.fanout == nil && key.Matches(msg, keys.Select) {
+		return app.setCollapsed(!header.collapsed), true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var groupTestSubscriptions = []Subscription{
+	{ID: "sub-1", Name: "Prod", TenantID: "t-2", TenantDisplayName: "Fabrikam", IsDefault: true},
+	{ID: "sub-2", Name: "Dev", TenantID: "t-1", TenantDisplayName: "Contoso"},
+	{ID: "sub-3", Name: "Test", TenantID: "t-2", TenantDisplayName: "Fabrikam"},
+	{ID: "sub-4", Name: "Sandbox", TenantID: "t-1", TenantDisplayName: "Contoso"},
+}
+
+// newGroupedApp returns a loaded app showing the grouped view
+func newGroupedApp(t *testing.T) *App {
+	t.Helper()
+
+	app := NewApp(NewFakeProvider(groupTestSubscriptions))
+	app.grouped = true
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+	return app
+}
+
+// itemLabels describes the list items, headers as "[name n]"
+func itemLabels(items []list.Item) string {
+	labels := make([]string, len(items))
+	for i, item := range items {
+		switch item := item.(type) {
+		case tenantHeader:
+			labels[i] = "[" + item.name + " " + strconv.Itoa(item.count) + "]"
+		case Subscription:
+			labels[i] = item.Name
+		}
+	}
+	return strings.Join(labels, ",")
+}
+
+func TestApp_GroupedItems(t *testing.T) {
+	app := newGroupedApp(t)
+	app.favorites.Toggle("sub-4")
+	app.setListItems("sub-1")
+
+	if labels := itemLabels(app.list.Items()); labels != "[Contoso 2],Sandbox,Dev,[Fabrikam 2],Prod,Test" {
+		t.Errorf("Expected tenants by name with favorites first, got %s", labels)
+	}
+	if sub, ok := app.list.SelectedItem().(Subscription); !ok || sub.ID != "sub-1" {
+		t.Errorf("Expected the active subscription highlighted, got %#v", app.list.SelectedItem())
+	}
+
+	view := app.View()
+	if !strings.Contains(view, ExpandedMarker+"Contoso") || !strings.Contains(view, "(2)") {
+		t.Errorf("Expected tenant headers with counts, got:\n%s", view)
+	}
+	if strings.Contains(view, "Fabrikam / Prod") || !strings.Contains(view, groupIndent+"Prod") {
+		t.Errorf("Expected indented subscriptions without the tenant, got:\n%s", view)
+	}
+}
+
+func TestApp_CollapseExpand(t *testing.T) {
+	app := newGroupedApp(t)
+
+	app.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	header, ok := app.list.SelectedItem().(tenantHeader)
+	if !ok || header.tenantID != "t-2" || !header.collapsed {
+		t.Fatalf("Expected the Fabrikam header to be collapsed and highlighted, got %#v", app.list.SelectedItem())
+	}
+	if labels := itemLabels(app.list.Items()); labels != "[Contoso 2],Dev,Sandbox,[Fabrikam 2]" {
+		t.Errorf("Expected Fabrikam's subscriptions hidden, got %s", labels)
+	}
+	if !strings.Contains(app.View(), CollapsedMarker+"Fabrikam") {
+		t.Error("Expected the collapsed marker")
+	}
+
+	app.Update(tea.KeyMsg{Type: tea.KeyRight})
+	if labels := itemLabels(app.list.Items()); labels != "[Contoso 2],Dev,Sandbox,[Fabrikam 2],Prod,Test" {
+		t.Errorf("Expected Fabrikam expanded again, got %s", labels)
+	}
+
+	// Enter on a header folds it instead of switching
+	_, cmd := app.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if !app.collapsed["t-2"] || cmd != nil {
+		t.Error("Expected enter to collapse the highlighted tenant")
+	}
+}
+
+func TestApp_GroupedFilter(t *testing.T) {
+	app := newGroupedApp(t)
+	app.Update(tea.KeyMsg{Type: tea.KeyLeft})
+
+	app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	if labels := itemLabels(app.list.Items()); labels != "Dev,Sandbox,Prod,Test" {
+		t.Errorf("Expected every subscription without headers while filtering, got %s", labels)
+	}
+
+	app.list.SetFilterText("test")
+	if visible := app.list.VisibleItems(); len(visible) != 1 || visible[0].(Subscription).ID != "sub-3" {
+		t.Errorf("Expected the collapsed subscription to be found, got %v", visible)
+	}
+	if view := app.View(); !strings.Contains(view, "Fabrikam / Test") {
+		t.Errorf("Expected filtered results to show the tenant, got:\n%s", view)
+	}
+
+	app.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if labels := itemLabels(app.list.Items()); labels != "[Contoso 2],Dev,Sandbox,[Fabrikam 2]" {
+		t.Errorf("Expected the groups back with their state after filtering, got %s", labels)
+	}
+}
+
+func TestApp_ToggleGrouped(t *testing.T) {
+	app := NewApp(NewFakeProvider(groupTestSubscriptions))
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+
+	app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyGroup)})
+	if !app.grouped || !strings.HasPrefix(itemLabels(app.list.Items()), "[Contoso 2]") {
+		t.Fatalf("Expected the grouped view, got %s", itemLabels(app.list.Items()))
+	}
+	if sub, ok := app.list.SelectedItem().(Subscription); !ok || sub.ID != "sub-1" {
+		t.Errorf("Expected the highlight to be kept, got %#v", app.list.SelectedItem())
+	}
+
+	app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyGroup)})
+	if app.grouped || strings.Contains(itemLabels(app.list.Items()), "[") {
+		t.Errorf("Expected the flat view, got %s", itemLabels(app.list.Items()))
+	}
+
+	config := DefaultConfig()
+	config.View = ViewTenant
+	if app := newUserApp(config, NewFakeProvider(groupTestSubscriptions)); !app.grouped {
+		t.Error("Expected view = tenant to start grouped")
+	}
+}
+
+func TestApp_FanoutSelectTenant(t *testing.T) {
+	app := newGroupedApp(t)
+	app.enableFanout(NewFanoutRunner(t.TempDir(), []string{"true"}, 1))
+	app.setListItems("sub-1")
+
+	app.Update(tea.KeyMsg{Type: tea.KeyUp})
+	space := tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+	app.Update(space)
+	if len(app.selected) != 2 || !app.selected["sub-1"] || !app.selected["sub-3"] {
+		t.Fatalf("Expected the tenant's subscriptions to be selected, got %v", app.selected)
+	}
+
+	app.Update(space)
+	if len(app.selected) != 0 {
+		t.Errorf("Expected the tenant to be cleared, got %v", app.selected)
+	}
+}
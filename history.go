@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// History configuration
+const (
+	// CommandToggle switches back to the previous subscription, like 'cd -'
+	CommandToggle = "-"
+
+	HistoryFileName = "history.jsonl"
+
+	// MaxHistoryEntries is how many switches are kept. The file is compacted
+	// once it holds twice as many.
+	MaxHistoryEntries = 100
+
+	// How a switch was made
+	SourceTUI  = "tui"
+	SourceCLI  = "cli"
+	SourceHook = "hook"
+)
+
+// ErrNoPreviousSubscription is returned by 'asubselect -' without a usable history
+var ErrNoPreviousSubscription = errors.New("no previous subscription to switch back to")
+
+// HistoryEntry records a single subscription switch
+type HistoryEntry struct {
+	Time           time.Time `json:"time"`
+	SubscriptionID string    `json:"subscriptionId"`
+	TenantID       string    `json:"tenantId"`
+	Source         string    `json:"source"`
+}
+
+// History is the persisted log of subscription switches, oldest first
+type History struct {
+	path    string
+	entries []HistoryEntry
+}
+
+// stateDir returns the directory holding asubselect's state
+func stateDir() (string, error) {
+	return xdgDir(EnvXDGStateHome, filepath.Join(".local", "state"))
+}
+
+// defaultHistoryPath returns the history file in the state directory
+func defaultHistoryPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, HistoryFileName), nil
+}
+
+// LoadHistory reads the history from path. A missing file yields an empty
+// history, and an empty path yields one that is never saved.
+func LoadHistory(path string) (*History, error) {
+	history := &History{path: path}
+	if path == "" {
+		return history, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return history, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return history, fmt.Errorf("failed to parse %s line %d: %w", path, line, err)
+		}
+		history.entries = append(history.entries, entry)
+	}
+
+	return history, scanner.Err()
+}
+
+// loadUserHistory loads the user's history. Problems reading it are reported
+// on stderr but not fatal, so a broken file never prevents switching.
+func loadUserHistory() *History {
+	path, err := defaultHistoryPath()
+	if err == nil {
+		var history *History
+		if history, err = LoadHistory(path); err == nil {
+			return history
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	return &History{}
+}
+
+// Entries returns the recorded switches, most recent first
+func (h *History) Entries() []HistoryEntry {
+	entries := slices.Clone(h.entries)
+	slices.Reverse(entries)
+	return entries
+}
+
+// Record appends a switch to subscription made via source
+func (h *History) Record(subscription Subscription, source string) error {
+	entry := HistoryEntry{
+		Time:           time.Now().UTC(),
+		SubscriptionID: subscription.ID,
+		TenantID:       subscription.TenantID,
+		Source:         source,
+	}
+	h.entries = append(h.entries, entry)
+
+	if h.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(h.path), err)
+	}
+
+	if len(h.entries) > 2*MaxHistoryEntries {
+		h.entries = slices.Clone(h.entries[len(h.entries)-MaxHistoryEntries:])
+		var buf bytes.Buffer
+		for _, entry := range h.entries {
+			if err := writeHistoryEntry(&buf, entry); err != nil {
+				return err
+			}
+		}
+		return writeFileAtomic(h.path, buf.Bytes())
+	}
+
+	file, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", h.path, err)
+	}
+	defer file.Close()
+
+	return writeHistoryEntry(file, entry)
+}
+
+// RecordSwitch records a switch from activeID to subscription made via
+// source. The active subscription is recorded first when the history does not
+// end with it, as after choosing it outside asubselect, so switching back
+// returns to it.
+func (h *History) RecordSwitch(subscriptions []Subscription, activeID string, subscription Subscription, source string) error {
+	if activeID != "" && activeID != subscription.ID &&
+		(len(h.entries) == 0 || h.entries[len(h.entries)-1].SubscriptionID != activeID) {
+		active := Subscription{ID: activeID}
+		if index := slices.IndexFunc(subscriptions, func(sub Subscription) bool { return sub.ID == activeID }); index >= 0 {
+			active = subscriptions[index]
+		}
+		if err := h.Record(active, source); err != nil {
+			return err
+		}
+	}
+	return h.Record(subscription, source)
+}
+
+// writeHistoryEntry writes entry as a single JSON line
+func writeHistoryEntry(out io.Writer, entry HistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode history entry: %w", err)
+	}
+	_, err = out.Write(append(data, '\n'))
+	return err
+}
+
+// sortByRecency orders subscriptions most recently used first, keeping the
+// original order for those never switched to
+func (h *History) sortByRecency(subscriptions []Subscription) []Subscription {
+	rank := make(map[string]int, len(h.entries))
+	for i, entry := range h.entries {
+		rank[entry.SubscriptionID] = i + 1
+	}
+
+	sorted := slices.Clone(subscriptions)
+	slices.SortStableFunc(sorted, func(a, b Subscription) int {
+		return rank[b.ID] - rank[a.ID]
+	})
+	return sorted
+}
+
+// Previous returns the most recently used subscription ID other than activeID
+func (h *History) Previous(activeID string) (string, bool) {
+	for _, entry := range h.Entries() {
+		if entry.SubscriptionID != activeID {
+			return entry.SubscriptionID, true
+		}
+	}
+	return "", false
+}
+
+// runToggle implements 'asubselect -', switching back to the previously
+// used subscription without opening the TUI
+func runToggle(provider SubscriptionProvider, history *History, stdout io.Writer) error {
+	subscriptions, err := provider.List()
+	if err != nil {
+		return fmt.Errorf("failed to fetch subscription data: %w", err)
+	}
+
+	activeID := activeSubscriptionID(subscriptions, activeCloud(provider))
+	previousID, ok := history.Previous(activeID)
+	if !ok {
+		return ErrNoPreviousSubscription
+	}
+
+	index := slices.IndexFunc(subscriptions, func(sub Subscription) bool { return sub.ID == previousID })
+	if index < 0 {
+		return fmt.Errorf("%w: %s is no longer available", ErrNoPreviousSubscription, previousID)
+	}
+	subscription := subscriptions[index]
+
+	result, err := switchSubscription(provider, subscription, activeID)
+	if err != nil {
+		return err
+	}
+	if err := history.RecordSwitch(subscriptions, activeID, subscription, SourceCLI); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	printSwitchResult(stdout, result, subscription)
+	return nil
+}
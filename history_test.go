@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var historyTestSubscriptions = []Subscription{
+	{ID: "sub-1", Name: "First", TenantID: "tenant-1", IsDefault: true},
+	{ID: "sub-2", Name: "Second", TenantID: "tenant-2"},
+	{ID: "sub-3", Name: "Third", TenantID: "tenant-1"},
+}
+
+func TestHistory_RecordAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", HistoryFileName)
+
+	history, err := LoadHistory(path)
+	if err != nil || len(history.Entries()) != 0 {
+		t.Fatalf("Expected an empty history for a missing file, got %v (%v)", history.Entries(), err)
+	}
+
+	for i, source := range []string{SourceCLI, SourceTUI, SourceHook} {
+		if err := history.Record(historyTestSubscriptions[i], source); err != nil {
+			t.Fatalf("Expected no error recording, got: %v", err)
+		}
+	}
+
+	reloaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("Expected no error reloading, got: %v", err)
+	}
+
+	entries := reloaded.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	if entries[0].SubscriptionID != "sub-3" || entries[0].TenantID != "tenant-1" || entries[0].Source != SourceHook {
+		t.Errorf("Expected the hook switch to sub-3 first, got %+v", entries[0])
+	}
+	if entries[2].Source != SourceCLI || entries[2].Time.IsZero() {
+		t.Errorf("Expected a timestamped CLI switch last, got %+v", entries[2])
+	}
+}
+
+func TestHistory_Compaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HistoryFileName)
+	history, _ := LoadHistory(path)
+
+	for i := 0; i <= 2*MaxHistoryEntries; i++ {
+		if err := history.Record(historyTestSubscriptions[i%3], SourceCLI); err != nil {
+			t.Fatalf("Expected no error recording, got: %v", err)
+		}
+	}
+
+	reloaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("Expected no error reloading, got: %v", err)
+	}
+	if len(reloaded.Entries()) != MaxHistoryEntries {
+		t.Errorf("Expected history to be compacted to %d entries, got %d", MaxHistoryEntries, len(reloaded.Entries()))
+	}
+}
+
+func TestLoadHistory_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HistoryFileName)
+	if err := os.WriteFile(path, []byte("{\"subscriptionId\":\"sub-1\"}\nnot json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadHistory(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected a parse error on line 2, got %v", err)
+	}
+}
+
+func TestHistory_SortByRecency(t *testing.T) {
+	history := &History{}
+	history.Record(historyTestSubscriptions[2], SourceCLI)
+	history.Record(historyTestSubscriptions[1], SourceCLI)
+
+	sorted := history.sortByRecency(historyTestSubscriptions)
+	var ids []string
+	for _, sub := range sorted {
+		ids = append(ids, sub.ID)
+	}
+	if strings.Join(ids, ",") != "sub-2,sub-3,sub-1" {
+		t.Errorf("Expected most recently used first, got %v", ids)
+	}
+}
+
+func TestHistory_Previous(t *testing.T) {
+	history := &History{}
+	if _, ok := history.Previous("sub-1"); ok {
+		t.Error("Expected no previous subscription in an empty history")
+	}
+
+	history.Record(historyTestSubscriptions[0], SourceCLI)
+	history.Record(historyTestSubscriptions[1], SourceCLI)
+
+	if id, ok := history.Previous("sub-2"); !ok || id != "sub-1" {
+		t.Errorf("Expected sub-1, got %q", id)
+	}
+	if id, ok := history.Previous("sub-3"); !ok || id != "sub-2" {
+		t.Errorf("Expected sub-2, got %q", id)
+	}
+}
+
+func TestRunToggle(t *testing.T) {
+	provider := NewFakeProvider(historyTestSubscriptions)
+	history := &History{}
+	history.Record(historyTestSubscriptions[1], SourceTUI)
+
+	// sub-1 was made the default outside asubselect
+	var out bytes.Buffer
+	if err := runToggle(provider, history, &out); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if current, _ := provider.Current(); current.ID != "sub-2" {
+		t.Errorf("Expected to toggle to sub-2, got %s", current.ID)
+	}
+	if !strings.Contains(out.String(), SuccessMessage) {
+		t.Errorf("Expected success message, got: %s", out.String())
+	}
+
+	if err := runToggle(provider, history, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if current, _ := provider.Current(); current.ID != "sub-1" {
+		t.Errorf("Expected to toggle back to sub-1, got %s", current.ID)
+	}
+}
+
+func TestRunToggle_NoHistory(t *testing.T) {
+	err := runToggle(NewFakeProvider(historyTestSubscriptions), &History{}, io.Discard)
+	if !errors.Is(err, ErrNoPreviousSubscription) {
+		t.Errorf("Expected ErrNoPreviousSubscription, got %v", err)
+	}
+
+	history := &History{}
+	history.Record(Subscription{ID: "gone"}, SourceCLI)
+	err = runToggle(NewFakeProvider(historyTestSubscriptions), history, io.Discard)
+	if !errors.Is(err, ErrNoPreviousSubscription) {
+		t.Errorf("Expected ErrNoPreviousSubscription for a removed subscription, got %v", err)
+	}
+}
+
+func TestApp_HistoryOrderingAndRecording(t *testing.T) {
+	app := NewApp(NewFakeProvider(historyTestSubscriptions))
+	app.history.Record(historyTestSubscriptions[2], SourceCLI)
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+
+	if first, ok := app.list.Items()[0].(Subscription); !ok || first.ID != "sub-3" {
+		t.Errorf("Expected the most recently used subscription first, got %#v", app.list.Items()[0])
+	}
+	if sub, ok := app.list.SelectedItem().(Subscription); !ok || sub.ID != "sub-1" {
+		t.Errorf("Expected the active subscription to stay highlighted, got %#v", app.list.SelectedItem())
+	}
+
+	msg := app.changeSubscription(historyTestSubscriptions[1])()
+	if changed := msg.(SubscriptionChangedMsg); !changed.Changed {
+		t.Fatal("Expected the subscription to change")
+	}
+	if entries := app.history.Entries(); entries[0].SubscriptionID == "sub-2" {
+		t.Error("Expected nothing recorded before the result reaches Update")
+	}
+	app.Update(msg)
+	if entries := app.history.Entries(); entries[0].SubscriptionID != "sub-2" || entries[0].Source != SourceTUI {
+		t.Errorf("Expected the TUI switch to be recorded, got %+v", entries[0])
+	}
+	if entries := app.history.Entries(); entries[1].SubscriptionID != "sub-1" {
+		t.Errorf("Expected the subscription switched away from recorded, got %+v", entries[1])
+	}
+}
+
+func TestRunToggle_AfterSet(t *testing.T) {
+	provider := NewFakeProvider(historyTestSubscriptions)
+	history := &History{}
+
+	if _, err := selectSubscription(DefaultConfig(), provider, history, "sub-2", io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := runToggle(provider, history, io.Discard); err != nil {
+		t.Fatalf("Expected to toggle after a single switch, got: %v", err)
+	}
+	if current, _ := provider.Current(); current.ID != "sub-1" {
+		t.Errorf("Expected to toggle back to sub-1, got %s", current.ID)
+	}
+}
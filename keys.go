@@ -0,0 +1,379 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// KeyHelp opens the help overlay
+const KeyHelp = "?"
+
+// Key map presets
+const (
+	KeyMapDefault = "default"
+	KeyMapVim     = "vim"
+	KeyMapEmacs   = "emacs"
+)
+
+var keyMapPresets = []string{KeyMapDefault, KeyMapVim, KeyMapEmacs}
+
+// HelpTitle heads the help overlay
+const HelpTitle = "Key bindings"
+
+// KeyBindings lists the keys bound to each action. Keys are named as
+// tea.KeyMsg.String reports them, with "space" accepted for " ".
+type KeyBindings struct {
+	Quit     []string
+	Select   []string
+	Back     []string
+	Retry    []string
+	Login    []string
+	Reauth   []string
+	Favorite []string
+	Env      []string
+	Toggle   []string
+	Help     []string
+
+	// Grouped view
+	Group    []string
+	Collapse []string
+	Expand   []string
+
+	// Clouds
+	Clouds   []string
+	AddCloud []string
+
+	// List navigation
+	Up       []string
+	Down     []string
+	PrevPage []string
+	NextPage []string
+	First    []string
+	Last     []string
+	Filter   []string
+}
+
+// DefaultKeyBindings returns the built-in key bindings
+func DefaultKeyBindings() KeyBindings {
+	return presetKeyBindings(KeyMapDefault)
+}
+
+// presetKeyBindings returns the key bindings of a preset. The default
+// preset matches the list's own navigation keys.
+func presetKeyBindings(preset string) KeyBindings {
+	keys := KeyBindings{
+		Quit:     []string{KeyQuit, KeyCtrlC},
+		Select:   []string{KeyEnter},
+		Back:     []string{KeyBack},
+		Retry:    []string{KeyRetry},
+		Login:    []string{KeyLogin},
+		Reauth:   []string{KeyReauth},
+		Favorite: []string{KeyFavorite},
+		Env:      []string{KeyEnv},
+		Toggle:   []string{KeyToggle},
+		Help:     []string{KeyHelp},
+		Group:    []string{KeyGroup},
+		Collapse: []string{"left"},
+		Expand:   []string{"right"},
+		Clouds:   []string{KeyClouds},
+		AddCloud: []string{KeyAddCloud},
+		Up:       []string{"up", "k"},
+		Down:     []string{"down", "j"},
+		PrevPage: []string{"h", "pgup", "b", "u"},
+		NextPage: []string{"l", "pgdown", "f", "d"},
+		First:    []string{"home", "g"},
+		Last:     []string{"end", "G"},
+		Filter:   []string{"/"},
+	}
+
+	switch preset {
+	case KeyMapVim:
+		keys.PrevPage = []string{"ctrl+b", "ctrl+u", "pgup"}
+		keys.NextPage = []string{"ctrl+f", "ctrl+d", "pgdown"}
+		keys.First = []string{"g", "home"}
+		keys.Last = []string{"G", "end"}
+		keys.Up = []string{"k", "up"}
+		keys.Down = []string{"j", "down"}
+		keys.Collapse = []string{"h", "left"}
+		keys.Expand = []string{"l", "right"}
+	case KeyMapEmacs:
+		keys.Quit = []string{KeyCtrlC, KeyQuit}
+		keys.Back = []string{"ctrl+g", KeyBack}
+		keys.Up = []string{"ctrl+p", "up"}
+		keys.Down = []string{"ctrl+n", "down"}
+		keys.PrevPage = []string{"alt+v", "pgup"}
+		keys.NextPage = []string{"ctrl+v", "pgdown"}
+		keys.First = []string{"alt+<", "home"}
+		keys.Last = []string{"alt+>", "end"}
+		keys.Filter = []string{"ctrl+s", "/"}
+	}
+
+	return keys
+}
+
+// actions maps [keys] entries to their bindings
+func (k *KeyBindings) actions() map[string]*[]string {
+	return map[string]*[]string{
+		"quit":      &k.Quit,
+		"select":    &k.Select,
+		"back":      &k.Back,
+		"retry":     &k.Retry,
+		"login":     &k.Login,
+		"reauth":    &k.Reauth,
+		"favorite":  &k.Favorite,
+		"env":       &k.Env,
+		"toggle":    &k.Toggle,
+		"help":      &k.Help,
+		"group":     &k.Group,
+		"collapse":  &k.Collapse,
+		"expand":    &k.Expand,
+		"clouds":    &k.Clouds,
+		"add_cloud": &k.AddCloud,
+		"up":        &k.Up,
+		"down":      &k.Down,
+		"prev_page": &k.PrevPage,
+		"next_page": &k.NextPage,
+		"first":     &k.First,
+		"last":      &k.Last,
+		"filter":    &k.Filter,
+	}
+}
+
+// keyContexts groups the actions that are available at the same time, and
+// so must not share keys
+var keyContexts = [][]string{
+	{"quit", "select", "back", "favorite", "env", "toggle", "help", "group", "collapse", "expand", "clouds",
+		"reauth", "up", "down", "prev_page", "next_page", "first", "last", "filter"},
+	{"quit", "retry", "login", "back", "help"},
+	{"quit", "back", "add_cloud", "help", "up", "down", "prev_page", "next_page", "first", "last", "filter"},
+}
+
+// conflicts reports a key bound to two actions available at the same time
+func (k *KeyBindings) conflicts() error {
+	actions := k.actions()
+	for _, context := range keyContexts {
+		owners := make(map[string]string)
+		for _, action := range context {
+			for _, name := range *actions[action] {
+				if owner, taken := owners[name]; taken && owner != action {
+					return fmt.Errorf("key %q is bound to both %s and %s", keyName([]string{name}), owner, action)
+				}
+				owners[name] = action
+			}
+		}
+	}
+	return nil
+}
+
+// merge returns k with the actions set in overrides replaced
+func (k KeyBindings) merge(overrides KeyBindings) KeyBindings {
+	actions := k.actions()
+	for action, keys := range overrides.actions() {
+		if *keys != nil {
+			*actions[action] = slices.Clone(*keys)
+		}
+	}
+	return k
+}
+
+// keyName returns a readable name for the first of keys, for help text
+func keyName(keys []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	if keys[0] == " " {
+		return "space"
+	}
+	return keys[0]
+}
+
+// keyNames returns readable names for all of keys, for the help overlay
+func keyNames(keys []string) string {
+	names := make([]string, len(keys))
+	for i := range keys {
+		names[i] = keyName(keys[i:])
+	}
+	return strings.Join(names, "/")
+}
+
+// KeyMap holds the bindings the TUI matches key presses against
+type KeyMap struct {
+	Quit     key.Binding
+	Select   key.Binding
+	Back     key.Binding
+	Retry    key.Binding
+	Login    key.Binding
+	Reauth   key.Binding
+	Favorite key.Binding
+	Env      key.Binding
+	Toggle   key.Binding
+	Help     key.Binding
+	Group    key.Binding
+	Collapse key.Binding
+	Expand   key.Binding
+	Clouds   key.Binding
+	AddCloud key.Binding
+
+	Up       key.Binding
+	Down     key.Binding
+	PrevPage key.Binding
+	NextPage key.Binding
+	First    key.Binding
+	Last     key.Binding
+	Filter   key.Binding
+}
+
+// KeyMap returns the bindings for the key lists
+func (k KeyBindings) KeyMap() KeyMap {
+	binding := func(keys []string, description string) key.Binding {
+		return key.NewBinding(key.WithKeys(keys...), key.WithHelp(keyNames(keys), description))
+	}
+
+	return KeyMap{
+		Quit:     binding(k.Quit, "quit"),
+		Select:   binding(k.Select, "select"),
+		Back:     binding(k.Back, "back"),
+		Retry:    binding(k.Retry, "retry"),
+		Login:    binding(k.Login, "log in"),
+		Reauth:   binding(k.Reauth, "log in to tenant"),
+		Favorite: binding(k.Favorite, "pin"),
+		Env:      binding(k.Env, "print env"),
+		Toggle:   binding(k.Toggle, "toggle"),
+		Help:     binding(k.Help, "help"),
+		Group:    binding(k.Group, "group by tenant"),
+		Collapse: binding(k.Collapse, "collapse"),
+		Expand:   binding(k.Expand, "expand"),
+		Clouds:   binding(k.Clouds, "clouds"),
+		AddCloud: binding(k.AddCloud, "register cloud"),
+		Up:       binding(k.Up, "up"),
+		Down:     binding(k.Down, "down"),
+		PrevPage: binding(k.PrevPage, "prev page"),
+		NextPage: binding(k.NextPage, "next page"),
+		First:    binding(k.First, "go to start"),
+		Last:     binding(k.Last, "go to end"),
+		Filter:   binding(k.Filter, "filter"),
+	}
+}
+
+// applyKeyMap makes the list follow the key map. The list quits and opens its
+// own help by itself, so those keys are routed through the app instead.
+func (app *App) applyKeyMap(l *list.Model) {
+	keys := app.keys
+	l.KeyMap.CursorUp = keys.Up
+	l.KeyMap.CursorDown = keys.Down
+	l.KeyMap.PrevPage = keys.PrevPage
+	l.KeyMap.NextPage = keys.NextPage
+	l.KeyMap.GoToStart = keys.First
+	l.KeyMap.GoToEnd = keys.Last
+	l.KeyMap.Filter = keys.Filter
+	l.KeyMap.ClearFilter = key.NewBinding(key.WithKeys(keys.Back.Keys()...), key.WithHelp(keys.Back.Help().Key, "clear filter"))
+	l.KeyMap.CancelWhileFiltering = key.NewBinding(key.WithKeys(keys.Back.Keys()...), key.WithHelp(keys.Back.Help().Key, "cancel"))
+	l.KeyMap.Quit = key.NewBinding(key.WithKeys(slices.Concat(keys.Quit.Keys(), keys.Back.Keys())...), key.WithHelp(keys.Quit.Help().Key, "quit"))
+	l.KeyMap.ShowFullHelp = keys.Help
+	l.KeyMap.CloseFullHelp.SetEnabled(false)
+	l.AdditionalShortHelpKeys = app.listHelpKeys
+}
+
+// listHelpKeys describes the app's own list actions in the list help
+func (app *App) listHelpKeys() []key.Binding {
+	if app.fanout != nil {
+		return []key.Binding{app.keys.Toggle}
+	}
+	if len(app.lockedTenants) > 0 {
+		return []key.Binding{app.keys.Favorite, app.keys.Env, app.keys.Reauth}
+	}
+	return []key.Binding{app.keys.Favorite, app.keys.Env}
+}
+
+// isTextInput reports whether msg should be typed into the filter rather
+// than trigger a binding
+func (app *App) isTextInput(msg tea.KeyMsg) bool {
+	settingFilter := app.state == StateSelectingSubscription && app.list.SettingFilter() ||
+		app.state == StateSelectingTenant && app.tenantList.SettingFilter() ||
+		app.state == StateManagingClouds && app.cloudList.SettingFilter() ||
+		app.state == StateAddingCloud
+	return settingFilter && (msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace)
+}
+
+// helpBindings returns the actions available in the current state, grouped
+// into columns for the help overlay
+func (app *App) helpBindings() [][]key.Binding {
+	keys := app.keys
+	general := []key.Binding{keys.Help, keys.Quit}
+
+	switch app.state {
+	case StateSelectingSubscription:
+		navigation := []key.Binding{keys.Up, keys.Down, keys.PrevPage, keys.NextPage, keys.First, keys.Last, keys.Filter}
+		actions := []key.Binding{keys.Select, keys.Favorite, keys.Env}
+		if len(app.lockedTenants) > 0 {
+			actions = append(actions, keys.Reauth)
+		}
+		if app.fanout != nil {
+			actions = []key.Binding{keys.Toggle, withHelp(keys.Select, "run command")}
+		}
+		view := []key.Binding{keys.Group}
+		if app.isGrouped() {
+			view = append(view, keys.Collapse, keys.Expand)
+		}
+		view = append(view, keys.Clouds)
+		back := withHelp(keys.Back, "clear filter")
+		if app.tenantFirst && app.fanout == nil {
+			back = withHelp(keys.Back, "clear filter / tenants")
+		}
+		return [][]key.Binding{navigation, actions, view, append([]key.Binding{back}, general...)}
+	case StateSelectingTenant:
+		navigation := []key.Binding{keys.Up, keys.Down, keys.PrevPage, keys.NextPage, keys.First, keys.Last, keys.Filter}
+		return [][]key.Binding{navigation, {withHelp(keys.Select, "show subscriptions")}, append([]key.Binding{withHelp(keys.Back, "clear filter")}, general...)}
+	case StateManagingClouds:
+		navigation := []key.Binding{keys.Up, keys.Down, keys.PrevPage, keys.NextPage, keys.First, keys.Last, keys.Filter}
+		return [][]key.Binding{navigation, {keys.AddCloud}, append([]key.Binding{withHelp(keys.Back, "clear filter / subscriptions")}, general...)}
+	case StateError:
+		actions := []key.Binding{keys.Back}
+		if appErr := app.classifyError(app.err); appErr.Retryable && app.retryCount < app.maxRetries {
+			actions = append([]key.Binding{keys.Retry}, actions...)
+		}
+		if app.canLogin() {
+			actions = append([]key.Binding{keys.Login}, actions...)
+		}
+		return [][]key.Binding{actions, general}
+	case StateShowingResult:
+		return [][]key.Binding{{withHelp(keys.Select, "continue"), withHelp(keys.Back, "continue")}, general}
+	case StateRunningFanout:
+		if app.fanoutDone {
+			return [][]key.Binding{{withHelp(keys.Select, "exit")}, general}
+		}
+	}
+
+	return [][]key.Binding{general}
+}
+
+// withHelp returns binding with a different description
+func withHelp(binding key.Binding, description string) key.Binding {
+	binding.SetHelp(binding.Help().Key, description)
+	return binding
+}
+
+// helpView renders the full-screen help overlay for the current state
+func (app *App) helpView() string {
+	h := help.New()
+	h.Styles.FullKey = lipgloss.NewStyle().Foreground(app.theme.Accent)
+	h.Styles.FullDesc = lipgloss.NewStyle().Foreground(app.theme.Text)
+	h.Styles.FullSeparator = lipgloss.NewStyle().Foreground(app.theme.InactiveBorder)
+
+	content := lipgloss.JoinVertical(lipgloss.Center,
+		lipgloss.NewStyle().Foreground(app.theme.Header).Bold(true).Render(HelpTitle),
+		"",
+		h.FullHelpView(app.helpBindings()),
+		"",
+		lipgloss.NewStyle().Foreground(app.theme.Subtext).Render("Press any key to close"),
+	)
+
+	return app.centeredView(content, app.theme.Text)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// isQuit reports whether cmd quits the program
+func isQuit(cmd tea.Cmd) bool {
+	if cmd == nil {
+		return false
+	}
+	_, ok := cmd().(tea.QuitMsg)
+	return ok
+}
+
+func TestKeyPresets_NoConflicts(t *testing.T) {
+	for _, preset := range keyMapPresets {
+		keys := presetKeyBindings(preset)
+		if err := keys.conflicts(); err != nil {
+			t.Errorf("Expected preset %s to be free of conflicts, got: %v", preset, err)
+		}
+		for action, bound := range keys.actions() {
+			if len(*bound) == 0 {
+				t.Errorf("Expected preset %s to bind %s", preset, action)
+			}
+		}
+	}
+
+	if vim := presetKeyBindings(KeyMapVim); !slices.Contains(vim.NextPage, "ctrl+f") {
+		t.Errorf("Expected vim paging keys, got %v", vim.NextPage)
+	}
+	if emacs := presetKeyBindings(KeyMapEmacs); !slices.Contains(emacs.Down, "ctrl+n") || !slices.Contains(emacs.Back, "ctrl+g") {
+		t.Errorf("Expected emacs navigation keys, got %v and %v", emacs.Down, emacs.Back)
+	}
+}
+
+func TestKeyBindings_Conflicts(t *testing.T) {
+	keys := DefaultKeyBindings()
+	keys.Favorite = []string{"j"}
+	if err := keys.conflicts(); err == nil || !strings.Contains(err.Error(), `key "j" is bound to both favorite and down`) {
+		t.Errorf("Expected a conflict between favorite and down, got %v", err)
+	}
+
+	// Retry is only available on the error screen, so it may reuse list keys
+	keys = DefaultKeyBindings()
+	keys.Retry = []string{"s"}
+	if err := keys.conflicts(); err != nil {
+		t.Errorf("Expected no conflict for keys in different states, got: %v", err)
+	}
+}
+
+func TestLoadConfig_KeyMap(t *testing.T) {
+	config, err := LoadConfig(writeTestConfig(t, "keymap = \"emacs\"\n\n[keys]\nfavorite = \"ctrl+t\"\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !slices.Equal(config.Keys.Up, []string{"ctrl+p", "up"}) || !slices.Equal(config.Keys.Favorite, []string{"ctrl+t"}) {
+		t.Errorf("Expected the emacs preset with overrides, got %+v", config.Keys)
+	}
+
+	_, err = LoadConfig(writeTestConfig(t, "[keys]\nenv = \"/\"\n"))
+	if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), "bound to both") {
+		t.Errorf("Expected a conflict at load time, got %v", err)
+	}
+
+	// A preset chosen by flag keeps the file's overrides, and is checked against them
+	path := writeTestConfig(t, "[keys]\nfavorite = \"ctrl+v\"\n")
+	config, err = loadUserConfig(path, map[string]string{"keymap": "vim"}, io.Discard)
+	if err != nil || !slices.Equal(config.Keys.Favorite, []string{"ctrl+v"}) || !slices.Contains(config.Keys.NextPage, "ctrl+f") {
+		t.Errorf("Expected the vim preset with the file's overrides, got %+v (%v)", config.Keys, err)
+	}
+	if _, err := loadUserConfig(path, map[string]string{"keymap": "emacs"}, io.Discard); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected a conflict between the emacs preset and the file, got %v", err)
+	}
+	if _, err := loadUserConfig(path, map[string]string{"keymap": "nano"}, io.Discard); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage for an unknown preset, got %v", err)
+	}
+}
+
+func TestKeyMap_Help(t *testing.T) {
+	keys := DefaultKeyBindings().KeyMap()
+	if help := keys.Quit.Help(); help.Key != "q/ctrl+c" || help.Desc != "quit" {
+		t.Errorf("Expected quit help to list every key, got %+v", help)
+	}
+	if help := keys.Toggle.Help(); help.Key != "space" {
+		t.Errorf("Expected space to be named, got %q", help.Key)
+	}
+}
+
+func TestApp_QuitKeyTypedIntoFilter(t *testing.T) {
+	app := NewApp(NewSampleProvider())
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+
+	app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	if !app.list.SettingFilter() {
+		t.Fatal("Expected the filter to be open")
+	}
+
+	for _, r := range "q?" {
+		if _, cmd := app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}); isQuit(cmd) {
+			t.Fatalf("Expected %q to be typed into the filter, not quit", r)
+		}
+	}
+	if app.list.FilterValue() != "q?" || app.showHelp {
+		t.Errorf("Expected the filter to contain \"q?\", got %q", app.list.FilterValue())
+	}
+
+	if _, cmd := app.Update(tea.KeyMsg{Type: tea.KeyCtrlC}); !isQuit(cmd) {
+		t.Error("Expected ctrl+c to quit while filtering")
+	}
+}
+
+func TestApp_HelpOverlay(t *testing.T) {
+	app := NewApp(NewSampleProvider())
+	app.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	app.Update(app.loadSubscriptions())
+
+	app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyHelp)})
+	if !app.showHelp {
+		t.Fatal("Expected ? to open the help overlay")
+	}
+
+	view := app.View()
+	for _, expected := range []string{HelpTitle, "pin", "print env", "go to start", "q/ctrl+c"} {
+		if !strings.Contains(view, expected) {
+			t.Errorf("Expected the help overlay to contain %q", expected)
+		}
+	}
+	if strings.Contains(view, "retry") {
+		t.Error("Expected retry not to be offered while selecting")
+	}
+
+	app.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if app.showHelp || app.list.Index() != 0 {
+		t.Error("Expected any key to close the overlay without acting")
+	}
+
+	// The error screen lists its own actions
+	app.state = StateError
+	app.err = errors.New("network timeout")
+	app.showHelp = true
+	if view := app.View(); !strings.Contains(view, "retry") || strings.Contains(view, "print env") {
+		t.Errorf("Expected the error actions only, got:\n%s", view)
+	}
+}
+
+func TestApp_KeyMapPreset(t *testing.T) {
+	config := DefaultConfig()
+	s, _ := findSetting("keymap")
+	if err := s.set(config, KeyMapEmacs); err != nil {
+		t.Fatal(err)
+	}
+
+	app := newUserApp(config, NewSampleProvider())
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+
+	start := app.list.Index()
+	app.Update(tea.KeyMsg{Type: tea.KeyCtrlN})
+	if app.list.Index() != start+1 {
+		t.Errorf("Expected ctrl+n to move down, got index %d", app.list.Index())
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Login methods for 'az login'
+const (
+	LoginBrowser = "browser"
+	LoginDevice  = "device"
+)
+
+var loginMethods = []string{LoginBrowser, LoginDevice}
+
+// KeyLogin runs 'az login' from the error view after an authentication error
+const KeyLogin = "l"
+
+// ErrLoginFailed is reported when 'az login' does not succeed
+var ErrLoginFailed = errors.New("azure login failed")
+
+// LoginFinishedMsg is sent when 'az login' has exited
+type LoginFinishedMsg struct {
+	Error error
+}
+
+// azureLoginArgs returns the 'az login' arguments for method, limited to
+// tenantID and requesting scope when set
+func azureLoginArgs(method, tenantID, scope string) []string {
+	args := []string{"login"}
+	if method == LoginDevice {
+		args = append(args, "--use-device-code")
+	}
+	if tenantID != "" {
+		args = append(args, "--tenant", tenantID)
+	}
+	if scope != "" {
+		args = append(args, "--scope", scope)
+	}
+	return args
+}
+
+// canLogin reports whether the current error can be fixed by logging in
+func (app *App) canLogin() bool {
+	return app.state == StateError && app.err != nil && app.classifyError(app.err).Type == ErrorTypeAuth
+}
+
+// login suspends the TUI to run 'az login' for tenantID, if known, remembering
+// the highlighted subscription to restore afterwards
+func (app *App) login(tenantID, scope string) tea.Cmd {
+	if !isAzureCLIAvailable(app.config.AzureCommand) {
+		app.err = ErrAzureCLINotFound
+		return nil
+	}
+
+	app.failedTenant = tenantID
+	app.restoreID = app.selectedID
+	if sub, ok := app.list.SelectedItem().(Subscription); ok {
+		app.restoreID = sub.ID
+	}
+
+	cmd := exec.Command(app.config.AzureCommand, azureLoginArgs(app.config.Login, tenantID, scope)...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return LoginFinishedMsg{Error: err}
+	})
+}
+
+// handleLoginFinished reloads the subscriptions after a successful login
+func (app *App) handleLoginFinished(msg LoginFinishedMsg) (tea.Model, tea.Cmd) {
+	if msg.Error != nil {
+		var exitErr *exec.ExitError
+		if errors.As(msg.Error, &exitErr) {
+			// az has already explained the failure on the terminal
+			msg.Error = fmt.Errorf("az login exited with code %d", exitErr.ExitCode())
+		}
+		app.err = fmt.Errorf("%w: %v", ErrLoginFailed, msg.Error)
+		app.state = StateError
+		app.restoreID = ""
+		return app, nil
+	}
+
+	app.err = nil
+	delete(app.lockedTenants, app.failedTenant)
+	app.failedTenant = ""
+	app.retryCount = 0
+	app.state = StateLoading
+	return app, tea.Batch(app.spinner.Tick, app.loadSubscriptions)
+}
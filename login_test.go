@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestAzureLoginArgs(t *testing.T) {
+	tests := []struct {
+		method, tenant, scope string
+		want                  string
+	}{
+		{method: LoginBrowser, want: "login"},
+		{method: LoginDevice, want: "login --use-device-code"},
+		{method: LoginBrowser, tenant: "t-2", want: "login --tenant t-2"},
+		{method: LoginDevice, tenant: "t-2", want: "login --use-device-code --tenant t-2"},
+		{method: LoginBrowser, tenant: "t-2", scope: DefaultLoginScope, want: "login --tenant t-2 --scope " + DefaultLoginScope},
+	}
+
+	for _, tt := range tests {
+		if got := strings.Join(azureLoginArgs(tt.method, tt.tenant, tt.scope), " "); got != tt.want {
+			t.Errorf("azureLoginArgs(%q, %q, %q) = %q, want %q", tt.method, tt.tenant, tt.scope, got, tt.want)
+		}
+	}
+}
+
+// newLoginApp returns a loaded app whose switch to the second subscription
+// failed for lack of authentication
+func newLoginApp(t *testing.T) (*App, *FakeProvider) {
+	t.Helper()
+
+	provider := NewFakeProvider(groupTestSubscriptions)
+	provider.SetErr = ErrUnauthorized
+	app := NewApp(provider)
+	// Any executable will do, as the login command is not run
+	app.config.AzureCommand = "go"
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+
+	app.list.Select(1)
+	sub := app.list.SelectedItem().(Subscription)
+	app.Update(app.changeSubscription(sub)())
+	if app.state != StateError {
+		t.Fatalf("Expected StateError, got %v", app.state)
+	}
+	return app, provider
+}
+
+func TestApp_Login(t *testing.T) {
+	app, provider := newLoginApp(t)
+	highlighted := app.list.SelectedItem().(Subscription)
+
+	if !app.canLogin() || app.failedTenant != highlighted.TenantID {
+		t.Fatalf("Expected a login for tenant %s, got %v for %q", highlighted.TenantID, app.canLogin(), app.failedTenant)
+	}
+	if view := app.View(); !strings.Contains(view, "Press '"+KeyLogin+"' to log in") {
+		t.Errorf("Expected the login action offered, got:\n%s", view)
+	}
+
+	_, cmd := app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyLogin)})
+	if cmd == nil || app.restoreID != highlighted.ID {
+		t.Fatalf("Expected az login to run remembering %s, got %q", highlighted.ID, app.restoreID)
+	}
+
+	// Once logged in, the subscriptions are reloaded with the selection kept
+	provider.SetErr = nil
+	app.Update(LoginFinishedMsg{})
+	if app.state != StateLoading || app.err != nil || app.failedTenant != "" {
+		t.Fatalf("Expected a reload, got %v with %v", app.state, app.err)
+	}
+	app.list.Select(0)
+	app.Update(app.loadSubscriptions())
+	if app.state != StateSelectingSubscription {
+		t.Fatalf("Expected StateSelectingSubscription, got %v", app.state)
+	}
+	if sub := app.list.SelectedItem().(Subscription); sub.ID != highlighted.ID {
+		t.Errorf("Expected %s highlighted again, got %s", highlighted.ID, sub.ID)
+	}
+}
+
+func TestApp_Login_Failed(t *testing.T) {
+	app, _ := newLoginApp(t)
+	app.login(app.failedTenant, "")
+
+	app.Update(LoginFinishedMsg{Error: errors.New("interrupted")})
+	if app.state != StateError || !errors.Is(app.err, ErrLoginFailed) {
+		t.Fatalf("Expected ErrLoginFailed, got %v with %v", app.state, app.err)
+	}
+	if !app.canLogin() || app.restoreID != "" {
+		t.Error("Expected another login to be offered")
+	}
+}
+
+func TestApp_Login_TenantFirst(t *testing.T) {
+	app, provider := newLoginApp(t)
+	app.tenantFirst = true
+	app.login(app.failedTenant, "")
+
+	provider.SetErr = nil
+	app.Update(LoginFinishedMsg{})
+	app.Update(app.loadSubscriptions())
+	if app.state != StateSelectingSubscription {
+		t.Fatalf("Expected the restored tenant's subscriptions, got %v", app.state)
+	}
+	restored := app.list.SelectedItem().(Subscription)
+	for _, item := range app.list.Items() {
+		if sub := item.(Subscription); sub.TenantID != restored.TenantID {
+			t.Errorf("Expected only tenant %s, got %s", restored.TenantID, sub.TenantID)
+		}
+	}
+}
+
+func TestApp_Login_NotOffered(t *testing.T) {
+	app := NewApp(NewSampleProvider())
+	app.err = errors.New("permission denied")
+	app.state = StateError
+
+	if app.canLogin() {
+		t.Error("Expected no login for a permission error")
+	}
+	if _, cmd := app.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(KeyLogin)}); cmd != nil {
+		t.Error("Expected the login key to be ignored")
+	}
+}
+
+func TestApp_Login_FailedTenantReset(t *testing.T) {
+	app, provider := newLoginApp(t)
+
+	// Leaving the error forgets the tenant of the failed switch
+	app.Update(BackMsg{})
+	if app.failedTenant != "" {
+		t.Errorf("Expected the failed tenant cleared, got %q", app.failedTenant)
+	}
+
+	// A later load error is not tied to that tenant
+	app.Update(app.changeSubscription(app.list.SelectedItem().(Subscription))())
+	if app.failedTenant == "" {
+		t.Fatal("Expected the failed switch to set the tenant")
+	}
+	provider.ListErr = ErrUnauthorized
+	app.Update(app.loadSubscriptions())
+	if app.state != StateError || app.failedTenant != "" {
+		t.Errorf("Expected a load error without a tenant, got %v for %q", app.state, app.failedTenant)
+	}
+}
@@ -2,19 +2,25 @@
 package main
 
 import (
+	"context"
 	_ "embed"
-	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"net"
 	"os"
 	"os/exec"
 	"slices"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/timer"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -28,20 +34,26 @@ const (
 	ExitCodeSuccess = 0
 	// ExitCodeError indicates an error occurred during execution
 	ExitCodeError = 1
+	// ExitCodeNotLoggedIn indicates there is no active subscription
+	ExitCodeNotLoggedIn = 2
 
-	// Environment variables
+	// AppName is used for flag usage output
+	AppName = "asubselect"
+
+	// Environment variables. Settings are also read from ASUBSELECT_<SETTING>.
 	EnvUseSampleData = "USE_SAMPLE_DATA"
 
-	// Key bindings
+	// Default key bindings
 	KeyQuit  = "q"
 	KeyCtrlC = "ctrl+c"
 	KeyEnter = "enter"
 	KeyRetry = "r"
 	KeyBack  = "esc"
 
-	// Azure CLI configuration
+	// Azure CLI configuration. AzureCommand is the default executable.
 	AzureCommand   = "az"
-	AzureListQuery = "[].{id:id,name:name,tenantDisplayName:tenantDisplayName,isDefault:isDefault,user:{name:user.name}}"
+	AzureListQuery = "[].{id:id,name:name,cloudName:cloudName,tenantId:tenantId,tenantDisplayName:tenantDisplayName,isDefault:isDefault,user:{name:user.name}}"
+	AzureShowQuery = "{id:id,name:name,cloudName:cloudName,tenantId:tenantId,tenantDisplayName:tenantDisplayName,isDefault:isDefault,user:{name:user.name}}"
 
 	// UI text
 	AppTitle        = "Select Azure Subscription"
@@ -61,6 +73,24 @@ var (
 	ErrSubscriptionAccess = errors.New("insufficient permissions for subscription")
 )
 
+// ExitError terminates the program with a specific exit code. Err, when set,
+// is reported on stderr.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 // Error types for different scenarios
 type AppError struct {
 	Type       ErrorType
@@ -83,11 +113,12 @@ const (
 	ErrorTypeUnknown
 )
 
-// Retry configuration
+// Retry and timing defaults, overridable in the configuration
 const (
-	MaxRetries = 3
-	BaseDelay  = 500 * time.Millisecond
-	MaxDelay   = 5 * time.Second
+	MaxRetries    = 3
+	BaseDelay     = 500 * time.Millisecond
+	MaxDelay      = 5 * time.Second
+	ResultTimeout = 1 * time.Second
 )
 
 // Global styles
@@ -107,10 +138,18 @@ const (
 	StateSelectingSubscription
 	StateShowingResult
 	StateError
+	StateRunningFanout
+	StateSelectingTenant
+	StateManagingClouds
+	StateAddingCloud
 )
 
 // App represents the main application state
 type App struct {
+	provider      SubscriptionProvider
+	config        *Config
+	theme         Theme
+	keys          KeyMap
 	state         AppState
 	spinner       spinner.Model
 	list          list.Model
@@ -121,22 +160,88 @@ type App struct {
 	retryCount    int
 	maxRetries    int
 	lastOperation string
+
+	// Login: the tenant whose authentication failed, if known, and the
+	// subscription to highlight once reloaded after logging in
+	failedTenant string
+	restoreID    string
+
+	// lockedTenants holds the tenants that asked for multi-factor
+	// authentication again, by tenant ID
+	lockedTenants map[string]*ClaimsChallengeError
+
+	// showHelp shows the key bindings for the current state over the view
+	showHelp bool
+
+	// initialMatches limits the list to these subscription IDs once loaded,
+	// for a query that matched several
+	initialMatches []string
+
+	// Fan-out mode: multi-select in the list and run a command on each
+	fanout        *FanoutRunner
+	selected      map[string]bool
+	fanoutResults []FanoutResult
+	fanoutDone    bool
+	fanoutOutput  viewport.Model
+
+	// favorites are shown first and persisted when toggled
+	favorites *Favorites
+
+	// history orders the list by recency and records switches
+	history *History
+
+	// Grouped view: subscriptions under collapsible tenant headers
+	grouped   bool
+	collapsed map[string]bool
+
+	// Tenant-first picker: choose a tenant, then one of its subscriptions
+	tenantFirst    bool
+	tenantList     list.Model
+	tenant         string
+	lastTenantPath string
+
+	// Clouds screen: the registered clouds and the form adding one
+	cloudList list.Model
+	cloudForm *CloudForm
+
+	// Env mode: enter prints export statements instead of switching
+	envMode         bool
+	envSubscription *Subscription
 }
 
 // Subscription represents an Azure subscription
 type Subscription struct {
 	ID                string `json:"id"`
 	Name              string `json:"name"`
+	CloudName         string `json:"cloudName"`
+	TenantID          string `json:"tenantId"`
 	TenantDisplayName string `json:"tenantDisplayName"`
 	IsDefault         bool   `json:"isDefault"`
 	User              struct {
 		Name string `json:"name"`
 	} `json:"user"`
+
+	// Alias and DisplayName come from the user's configuration
+	Alias       string `json:"alias,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
 }
 
 // Title implements list.Item interface
 func (s Subscription) Title() string {
-	return joinNonEmpty(" / ", s.TenantDisplayName, s.Name)
+	return joinNonEmpty(" / ", s.TenantDisplayName, s.ShortTitle())
+}
+
+// ShortTitle returns the title without the tenant
+func (s Subscription) ShortTitle() string {
+	title := s.Name
+	if s.DisplayName != "" {
+		title = s.DisplayName
+	}
+	if s.Alias != "" {
+		title += " [" + s.Alias + "]"
+	}
+
+	return title
 }
 
 // Description implements list.Item interface
@@ -150,7 +255,7 @@ func (s Subscription) Description() string {
 
 // FilterValue implements list.Item interface
 func (s Subscription) FilterValue() string {
-	return joinNonEmpty("/", s.Name, s.TenantDisplayName, s.User.Name)
+	return joinNonEmpty("/", s.Alias, s.DisplayName, s.Name, s.TenantDisplayName, s.CloudName, s.User.Name)
 }
 
 func joinNonEmpty(separator string, parts ...string) string {
@@ -169,13 +274,18 @@ func joinNonEmpty(separator string, parts ...string) string {
 type ResultPage struct {
 	changed bool
 	timer   timer.Model
+	theme   Theme
+
+	// fromCloud and toCloud are set when the cloud was switched first
+	fromCloud, toCloud string
 }
 
 // NewResultPage creates a new result page instance
-func NewResultPage(changed bool) *ResultPage {
+func NewResultPage(changed bool, timeout time.Duration, theme Theme) *ResultPage {
 	return &ResultPage{
 		changed: changed,
-		timer:   timer.New(1 * time.Second),
+		timer:   timer.New(timeout),
+		theme:   theme,
 	}
 }
 
@@ -198,10 +308,13 @@ func (rp *ResultPage) View() string {
 
 	if rp.changed {
 		text = SuccessMessage
-		color = Success
+		color = rp.theme.Success
 	} else {
 		text = NoChangeMessage
-		color = Info
+		color = rp.theme.Info
+	}
+	if rp.fromCloud != "" {
+		text = fmt.Sprintf(CloudChangedMessage, rp.fromCloud, rp.toCloud) + "\n" + text
 	}
 
 	return lipgloss.NewStyle().
@@ -218,13 +331,16 @@ func (rp *ResultPage) View() string {
 // SubscriptionsLoadedMsg is sent when subscriptions have been loaded
 type SubscriptionsLoadedMsg struct {
 	Subscriptions []Subscription
-	Error         error
-	AttemptCount  int
+	// ActiveCloud is the provider's active cloud, if it has clouds
+	ActiveCloud  string
+	Error        error
+	AttemptCount int
 }
 
 // SubscriptionChangedMsg is sent when a subscription change has been attempted
 type SubscriptionChangedMsg struct {
 	Changed      bool
+	FromCloud    string
 	Error        error
 	Subscription Subscription
 	AttemptCount int
@@ -236,11 +352,50 @@ type RetryMsg struct{}
 // BackMsg is sent to go back to subscription selection
 type BackMsg struct{}
 
-// NewApp creates a new application instance
-func NewApp() *App {
+// newUserApp creates an application instance with the user's configuration
+// and persisted favorites and history. Problems reading them are not fatal.
+func newUserApp(config *Config, provider SubscriptionProvider) *App {
+	app := newApp(provider, config, config.ActiveTheme())
+	app.history = loadUserHistory()
+
+	path, err := defaultLastTenantPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	app.lastTenantPath = path
+
+	path, err = defaultFavoritesPath()
+	if err == nil {
+		app.favorites, err = LoadFavorites(path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	return app
+}
+
+// NewApp creates a new application instance backed by the given provider
+func NewApp(provider SubscriptionProvider) *App {
+	return newApp(provider, DefaultConfig(), DefaultTheme())
+}
+
+// newApp creates an application instance with the given configuration and
+// theme, building its components once they are known
+func newApp(provider SubscriptionProvider, config *Config, theme Theme) *App {
 	app := &App{
-		state:      StateLoading,
-		maxRetries: MaxRetries,
+		provider:      provider,
+		config:        config,
+		theme:         theme,
+		keys:          config.Keys.KeyMap(),
+		grouped:       config.View == ViewTenant,
+		tenantFirst:   config.Picker == PickerTenant,
+		favorites:     &Favorites{},
+		history:       &History{},
+		collapsed:     make(map[string]bool),
+		lockedTenants: make(map[string]*ClaimsChallengeError),
+		state:         StateLoading,
+		maxRetries:    config.MaxRetries,
 	}
 
 	app.initializeSpinner()
@@ -253,7 +408,7 @@ func NewApp() *App {
 func (app *App) initializeSpinner() {
 	s := spinner.New()
 	s.Spinner = spinner.MiniDot
-	s.Style = lipgloss.NewStyle().Foreground(Text)
+	s.Style = lipgloss.NewStyle().Foreground(app.theme.Text)
 	app.spinner = s
 }
 
@@ -263,40 +418,146 @@ func (app *App) initializeList() {
 	subscriptionList := list.New([]list.Item{}, delegate, 0, 0)
 
 	app.styleList(&subscriptionList)
-	subscriptionList.Title = AppTitle
+	subscriptionList.Title = app.config.Title
+	app.applyKeyMap(&subscriptionList)
 
 	app.list = subscriptionList
+	app.initializeTenantList()
+	app.initializeCloudList()
+}
+
+// updateListTitle refreshes the list title, including the selection count
+// in fan-out mode
+func (app *App) updateListTitle() {
+	if app.fanout != nil {
+		app.list.Title = fmt.Sprintf("%s (%d selected)", FanoutTitle, len(app.selected))
+		return
+	}
+	if app.tenantFirst && app.tenant != "" {
+		app.list.Title = joinNonEmpty(" / ", app.config.Title, app.tenantName())
+		return
+	}
+	app.list.Title = app.config.Title
+}
+
+// itemPrefix returns the markers rendered before a subscription's title
+func (app *App) itemPrefix(sub Subscription) string {
+	var prefix string
+	if app.fanout != nil {
+		prefix = "[ ] "
+		if app.selected[sub.ID] {
+			prefix = "[x] "
+		}
+	}
+	if app.favorites.Contains(sub.ID) {
+		prefix += FavoriteMarker
+	}
+	if app.isLocked(sub) {
+		prefix += LockMarker
+	}
+	return prefix
+}
+
+// subscriptionDelegate renders subscriptions with per-item markers, indented
+// below their tenant in the grouped view
+type subscriptionDelegate struct {
+	list.DefaultDelegate
+	prefix  func(Subscription) string
+	grouped func() bool
+	theme   Theme
+}
+
+// markedSubscription is a Subscription whose title carries a marker prefix
+// and a cloud badge. Grouped subscriptions are indented and leave out the tenant.
+type markedSubscription struct {
+	Subscription
+	marker  string
+	grouped bool
+	badge   string
+}
+
+// Title implements list.DefaultItem interface
+func (m markedSubscription) Title() string {
+	title := m.marker + m.Subscription.Title()
+	if m.grouped {
+		title = groupIndent + m.marker + m.Subscription.ShortTitle()
+	}
+	return joinNonEmpty("  ", title, m.badge)
+}
+
+// Description implements list.DefaultItem interface
+func (m markedSubscription) Description() string {
+	if m.grouped {
+		return groupIndent + m.Subscription.Description()
+	}
+	return m.Subscription.Description()
+}
+
+// Render implements list.ItemDelegate interface
+func (d subscriptionDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	switch header := item.(type) {
+	case sectionHeader:
+		fmt.Fprint(w, renderSectionHeader(header, d.theme))
+		return
+	case tenantHeader:
+		fmt.Fprint(w, renderTenantHeader(header, index == m.Index(), d.theme))
+		return
+	}
+	if sub, ok := item.(Subscription); ok {
+		marker, grouped, badge := d.prefix(sub), d.grouped(), cloudBadge(sub)
+		if marker != "" || grouped || badge != "" {
+			item = markedSubscription{Subscription: sub, marker: marker, grouped: grouped, badge: badge}
+		}
+	}
+	d.DefaultDelegate.Render(w, m, index, item)
 }
 
 // createListDelegate creates a styled list delegate
-func (app *App) createListDelegate() list.DefaultDelegate {
+func (app *App) createListDelegate() subscriptionDelegate {
+	theme := app.theme
 	d := list.NewDefaultDelegate()
 	d.Styles.SelectedTitle = d.Styles.SelectedTitle.
-		Foreground(Rosewater).
-		BorderLeftForeground(ActiveBorder)
+		Foreground(theme.Accent).
+		BorderLeftForeground(theme.ActiveBorder)
 	d.Styles.SelectedDesc = d.Styles.SelectedTitle
-	d.Styles.NormalTitle = d.Styles.NormalTitle.Foreground(Text)
+	d.Styles.NormalTitle = d.Styles.NormalTitle.Foreground(theme.Text)
 	d.Styles.NormalDesc = d.Styles.NormalTitle
-	return d
+	d.Styles.DimmedTitle = d.Styles.DimmedTitle.Foreground(theme.Muted)
+	d.Styles.DimmedDesc = d.Styles.DimmedTitle
+	d.Styles.FilterMatch = d.Styles.FilterMatch.Foreground(theme.Info)
+	return subscriptionDelegate{DefaultDelegate: d, prefix: app.itemPrefix, grouped: app.isGrouped, theme: theme}
 }
 
 // styleList applies styling to the subscription list
 func (app *App) styleList(l *list.Model) {
+	theme := app.theme
 	l.Styles.Title = l.Styles.Title.
-		Foreground(Text).
-		Background(Mantle).
-		BorderBottomBackground(ActiveBorder).
+		Foreground(theme.Text).
+		Background(theme.TitleBackground).
+		BorderBottomBackground(theme.ActiveBorder).
 		Margin(0).
 		Padding(1, 0, 0, 0)
 
-	l.Styles.FilterCursor = l.Styles.FilterCursor.Foreground(Text)
-	l.Styles.FilterPrompt = l.Styles.FilterPrompt.Foreground(Rosewater)
+	l.Styles.FilterCursor = l.Styles.FilterCursor.Foreground(theme.Text)
+	l.Styles.FilterPrompt = l.Styles.FilterPrompt.Foreground(theme.Accent)
+	l.Styles.StatusBar = l.Styles.StatusBar.Foreground(theme.Subtext)
+	l.Styles.StatusEmpty = l.Styles.StatusEmpty.Foreground(theme.Muted)
+	l.Styles.NoItems = l.Styles.NoItems.Foreground(theme.Muted)
+	l.Styles.PaginationStyle = l.Styles.PaginationStyle.Foreground(theme.Muted)
+	l.Styles.ActivePaginationDot = l.Styles.ActivePaginationDot.Foreground(theme.Subtext)
+	l.Styles.InactivePaginationDot = l.Styles.InactivePaginationDot.Foreground(theme.InactiveBorder)
+	l.Help.Styles.ShortKey = l.Help.Styles.ShortKey.Foreground(theme.Subtext)
+	l.Help.Styles.ShortDesc = l.Help.Styles.ShortDesc.Foreground(theme.Muted)
+	l.Help.Styles.ShortSeparator = l.Help.Styles.ShortSeparator.Foreground(theme.InactiveBorder)
+	l.Help.Styles.FullKey = l.Help.Styles.ShortKey
+	l.Help.Styles.FullDesc = l.Help.Styles.ShortDesc
+	l.Help.Styles.FullSeparator = l.Help.Styles.ShortSeparator
 
 	// Apply workaround styles for filter input
 	l.FilterInput.PromptStyle = l.Styles.FilterPrompt.
-		Foreground(Text).
+		Foreground(theme.Text).
 		MarginLeft(1)
-	l.FilterInput.Cursor.Style = l.Styles.FilterCursor.Foreground(Rosewater)
+	l.FilterInput.Cursor.Style = l.Styles.FilterCursor.Foreground(theme.Accent)
 }
 
 // Init implements tea.Model interface
@@ -326,6 +587,16 @@ func (app *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return app.handleRetry(msg)
 	case BackMsg:
 		return app.handleBack(msg)
+	case FanoutProgressMsg:
+		return app.handleFanoutProgress(msg)
+	case FanoutFinishedMsg:
+		return app.handleFanoutFinished(msg)
+	case LoginFinishedMsg:
+		return app.handleLoginFinished(msg)
+	case CloudsLoadedMsg:
+		return app.handleCloudsLoaded(msg)
+	case CloudAddedMsg:
+		return app.handleCloudAdded(msg)
 	}
 
 	return app.updateSubComponents(msg)
@@ -333,31 +604,91 @@ func (app *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // handleKeyMsg processes keyboard input
 func (app *App) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	key := msg.String()
+	keys := app.keys
+
+	// Bound keys that are also text are typed into the filter
+	if app.isTextInput(msg) {
+		return app.updateSubComponents(msg)
+	}
 
-	if key == KeyQuit || key == KeyCtrlC {
+	if key.Matches(msg, keys.Quit) {
 		return app, tea.Quit
 	}
 
+	if app.showHelp {
+		app.showHelp = false
+		return app, nil
+	}
+	if key.Matches(msg, keys.Help) {
+		app.showHelp = true
+		return app, nil
+	}
+
 	switch app.state {
+	case StateSelectingTenant:
+		if key.Matches(msg, keys.Select) {
+			return app, app.chooseTenant()
+		}
+		return app.updateSubComponents(msg)
 	case StateSelectingSubscription:
-		if key == KeyEnter {
+		if app.tenantFirst && app.fanout == nil && app.list.FilterState() == list.Unfiltered && key.Matches(msg, keys.Back) {
+			return app, func() tea.Msg { return BackMsg{} }
+		}
+		if app.fanout != nil && !app.list.SettingFilter() {
+			switch {
+			case key.Matches(msg, keys.Toggle):
+				app.toggleSelected()
+				return app, nil
+			case key.Matches(msg, keys.Select):
+				return app, app.startFanout()
+			}
+		}
+		if !app.list.SettingFilter() {
+			if cmd, handled := app.handleGroupKey(msg); handled {
+				return app, cmd
+			}
+		}
+		if !app.list.SettingFilter() && app.fanout == nil && key.Matches(msg, keys.Clouds) {
+			return app, app.showClouds()
+		}
+		if !app.list.SettingFilter() && app.fanout == nil && key.Matches(msg, keys.Reauth) {
+			return app, app.reauthenticate()
+		}
+		if !app.list.SettingFilter() && key.Matches(msg, keys.Favorite) {
+			return app, app.toggleFavorite()
+		}
+		if !app.list.SettingFilter() && (key.Matches(msg, keys.Env) || (app.envMode && key.Matches(msg, keys.Select))) {
+			return app, app.chooseEnv()
+		}
+		if key.Matches(msg, keys.Select) {
 			if selectedSub, ok := app.list.SelectedItem().(Subscription); ok {
 				return app, app.changeSubscription(selectedSub)
 			}
 		}
 		return app.updateSubComponents(msg)
+	case StateManagingClouds, StateAddingCloud:
+		return app.handleCloudKey(msg)
 	case StateError:
-		if key == KeyRetry {
+		if app.canLogin() && key.Matches(msg, keys.Login) {
+			return app, app.login(loginTarget(app.err, app.failedTenant))
+		}
+		if key.Matches(msg, keys.Retry) {
 			return app, func() tea.Msg { return RetryMsg{} }
 		}
-		if key == KeyBack {
+		if key.Matches(msg, keys.Back) {
 			return app, func() tea.Msg { return BackMsg{} }
 		}
 	case StateShowingResult:
-		if key == KeyBack || key == KeyEnter {
+		if key.Matches(msg, keys.Back, keys.Select) {
 			return app, func() tea.Msg { return BackMsg{} }
 		}
+	case StateRunningFanout:
+		if app.fanoutDone && key.Matches(msg, keys.Select) {
+			return app, tea.Quit
+		}
+		var cmd tea.Cmd
+		app.fanoutOutput, cmd = app.fanoutOutput.Update(msg)
+		return app, cmd
 	}
 
 	return app, nil
@@ -370,13 +701,16 @@ func (app *App) handleWindowSizeMsg(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd)
 	// Update list size accounting for document style margins
 	h, v := docStyle.GetFrameSize()
 	app.list.SetSize(msg.Width-h, msg.Height-v)
+	app.tenantList.SetSize(msg.Width-h, msg.Height-v)
+	app.cloudList.SetSize(msg.Width-h, msg.Height-v)
+	app.resizeFanoutOutput()
 
 	return app, nil
 }
 
 // handleSpinnerMsg processes spinner tick messages
 func (app *App) handleSpinnerMsg(msg spinner.TickMsg) (tea.Model, tea.Cmd) {
-	if app.state != StateLoading {
+	if app.state != StateLoading && app.state != StateRunningFanout {
 		return app, nil
 	}
 
@@ -395,6 +729,9 @@ func (app *App) handleSubscriptionsLoaded(msg SubscriptionsLoadedMsg) (tea.Model
 			return app, app.retryOperation()
 		}
 		app.err = msg.Error
+		// Loading is not tied to a tenant, whatever failed before
+		app.failedTenant = ""
+		app.lockTenant(msg.Error, Subscription{})
 		app.state = StateError
 		return app, nil
 	}
@@ -403,21 +740,36 @@ func (app *App) handleSubscriptionsLoaded(msg SubscriptionsLoadedMsg) (tea.Model
 	app.subscriptions = msg.Subscriptions // Save subscriptions for retry logic
 	app.retryCount = 0                    // Reset retry count on success
 
-	// Convert subscriptions to list items
-	items := make([]list.Item, len(msg.Subscriptions))
-	for i, sub := range msg.Subscriptions {
-		items[i] = sub
+	matched := app.initialMatches != nil
+	if matched {
+		app.subscriptions = slices.DeleteFunc(slices.Clone(app.subscriptions), func(s Subscription) bool {
+			return !slices.Contains(app.initialMatches, s.ID)
+		})
+		app.initialMatches = nil
 	}
 
 	// Find and select the default subscription
-	defaultIndex := findDefaultSubscription(msg.Subscriptions)
-	if defaultIndex >= 0 {
-		app.selectedID = msg.Subscriptions[defaultIndex].ID
-		app.list.Select(defaultIndex)
+	app.selectedID = activeSubscriptionID(msg.Subscriptions, msg.ActiveCloud)
+
+	// After logging in, highlight the subscription chosen before
+	highlightID, restored := app.selectedID, false
+	if index := slices.IndexFunc(msg.Subscriptions, func(s Subscription) bool { return s.ID == app.restoreID }); index >= 0 {
+		highlightID, restored = app.restoreID, true
+		if app.tenantFirst {
+			app.tenant = msg.Subscriptions[index].TenantID
+			app.updateListTitle()
+		}
 	}
+	app.restoreID = ""
 
-	cmd := app.list.SetItems(items)
-	return app, cmd
+	// Start from the tenants unless there is a single one or a query to apply
+	if app.tenantFirst && !restored && !matched && len(app.groupByTenant(app.subscriptions)) > 1 {
+		app.tenant = ""
+		app.updateListTitle()
+		return app, app.showTenants()
+	}
+
+	return app, app.setListItems(highlightID)
 }
 
 // handleSubscriptionChanged processes subscription change results
@@ -430,12 +782,21 @@ func (app *App) handleSubscriptionChanged(msg SubscriptionChangedMsg) (tea.Model
 			return app, app.retryOperation()
 		}
 		app.err = msg.Error
+		app.failedTenant = msg.Subscription.TenantID
+		app.lockTenant(msg.Error, msg.Subscription)
 		app.state = StateError
 		return app, nil
 	}
 
+	if msg.Changed {
+		// History is best effort; the TUI has nowhere to warn about it.
+		// Recording here keeps it on the Update goroutine, which reads it.
+		_ = app.history.RecordSwitch(app.subscriptions, app.selectedID, msg.Subscription, SourceTUI)
+	}
+	delete(app.lockedTenants, msg.Subscription.TenantID)
 	app.selectedID = msg.Subscription.ID
-	app.resultPage = NewResultPage(msg.Changed)
+	app.resultPage = NewResultPage(msg.Changed, app.config.ResultTimeout, app.theme)
+	app.resultPage.fromCloud, app.resultPage.toCloud = msg.FromCloud, msg.Subscription.CloudName
 	app.state = StateShowingResult
 	app.retryCount = 0 // Reset retry count on success
 
@@ -449,8 +810,7 @@ func (app *App) handleRetry(msg RetryMsg) (tea.Model, tea.Cmd) {
 		app.state = StateLoading
 		return app, app.loadSubscriptions
 	case "change":
-		if len(app.subscriptions) > 0 && app.list.Index() < len(app.subscriptions) {
-			selectedSub := app.subscriptions[app.list.Index()]
+		if selectedSub, ok := app.list.SelectedItem().(Subscription); ok {
 			return app, app.changeSubscription(selectedSub)
 		}
 	}
@@ -464,8 +824,21 @@ func (app *App) handleRetry(msg RetryMsg) (tea.Model, tea.Cmd) {
 
 // handleBack processes back navigation
 func (app *App) handleBack(msg BackMsg) (tea.Model, tea.Cmd) {
+	// From the cloud form, go back to the clouds
+	if app.state == StateAddingCloud {
+		app.cloudForm = nil
+		app.state = StateManagingClouds
+		return app, nil
+	}
+
+	// From a tenant's subscriptions, go back to the tenants
+	if app.state == StateSelectingSubscription && app.tenantFirst && app.fanout == nil {
+		return app, app.showTenants()
+	}
+
 	app.state = StateSelectingSubscription
 	app.err = nil
+	app.failedTenant = ""
 	app.retryCount = 0
 	app.resultPage = nil
 	return app, nil
@@ -475,13 +848,40 @@ func (app *App) handleBack(msg BackMsg) (tea.Model, tea.Cmd) {
 func (app *App) updateSubComponents(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
+	switch app.state {
+	case StateSelectingTenant:
+		var cmd tea.Cmd
+		app.tenantList, cmd = app.tenantList.Update(msg)
+		cmds = append(cmds, cmd)
+	case StateManagingClouds:
+		var cmd tea.Cmd
+		app.cloudList, cmd = app.cloudList.Update(msg)
+		cmds = append(cmds, cmd)
+	case StateAddingCloud:
+		var cmd tea.Cmd
+		app.cloudForm, cmd = app.cloudForm.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+
 	// Update list
 	if app.state == StateSelectingSubscription {
 		var cmd tea.Cmd
+		previousIndex := app.list.Index()
+		wasFiltered := app.list.FilterState() != list.Unfiltered
 		app.list, cmd = app.list.Update(msg)
+		app.skipSectionHeader(previousIndex)
 		if cmd != nil {
 			cmds = append(cmds, cmd)
 		}
+
+		// Tenant headers are only shown while not filtering
+		if isFiltered := app.list.FilterState() != list.Unfiltered; app.grouped && isFiltered != wasFiltered {
+			highlightID := app.selectedID
+			if sub, ok := app.list.SelectedItem().(Subscription); ok {
+				highlightID = sub.ID
+			}
+			cmds = append(cmds, app.setListItems(highlightID))
+		}
 	}
 
 	// Update result page
@@ -505,6 +905,10 @@ func findDefaultSubscription(subscriptions []Subscription) int {
 
 // View implements tea.Model interface
 func (app *App) View() string {
+	if app.showHelp {
+		return app.helpView()
+	}
+
 	switch app.state {
 	case StateLoading:
 		return app.loadingView()
@@ -516,6 +920,14 @@ func (app *App) View() string {
 		return app.resultView()
 	case StateError:
 		return app.errorView()
+	case StateRunningFanout:
+		return app.fanoutView()
+	case StateSelectingTenant:
+		return app.tenantListView()
+	case StateManagingClouds:
+		return app.cloudListView()
+	case StateAddingCloud:
+		return app.cloudForm.View()
 	default:
 		return "Unknown state"
 	}
@@ -529,7 +941,7 @@ func (app *App) loadingView() string {
 		LoadingMessage,
 	)
 
-	return app.centeredView(content, Text)
+	return app.centeredView(content, app.theme.Text)
 }
 
 // subscriptionListView renders the subscription selection screen
@@ -549,31 +961,47 @@ func (app *App) resultView() string {
 func (app *App) retryingView() string {
 	content := fmt.Sprintf(RetryingMessage, app.retryCount, app.maxRetries)
 	spinner := lipgloss.JoinHorizontal(lipgloss.Top, app.spinner.View(), content)
-	return app.centeredView(spinner, Text)
+	return app.centeredView(spinner, app.theme.Text)
 }
 
 // errorView renders the error screen
 func (app *App) errorView() string {
 	appErr := app.classifyError(app.err)
 
+	keys := app.config.Keys
+
 	var content string
-	if appErr.Retryable && app.retryCount < app.maxRetries {
+	if app.canLogin() {
+		content = fmt.Sprintf(
+			"❌ %s\n\n💡 %s\n\n🔑 Press '%s' to log in • ← Press '%s' to go back • Press '%s' to quit",
+			appErr.Err.Error(),
+			appErr.Suggestion,
+			keyName(keys.Login),
+			keyName(keys.Back),
+			keyName(keys.Quit),
+		)
+	} else if appErr.Retryable && app.retryCount < app.maxRetries {
 		content = fmt.Sprintf(
-			"❌ %s\n\n💡 %s\n\n🔄 Press 'r' to retry (%d/%d) • ← Press 'esc' to go back • Press 'q' to quit",
+			"❌ %s\n\n💡 %s\n\n🔄 Press '%s' to retry (%d/%d) • ← Press '%s' to go back • Press '%s' to quit",
 			appErr.Err.Error(),
 			appErr.Suggestion,
+			keyName(keys.Retry),
 			app.retryCount,
 			app.maxRetries,
+			keyName(keys.Back),
+			keyName(keys.Quit),
 		)
 	} else {
 		content = fmt.Sprintf(
-			"❌ %s\n\n💡 %s\n\n← Press 'esc' to go back • Press 'q' to quit",
+			"❌ %s\n\n💡 %s\n\n← Press '%s' to go back • Press '%s' to quit",
 			appErr.Err.Error(),
 			appErr.Suggestion,
+			keyName(keys.Back),
+			keyName(keys.Quit),
 		)
 	}
 
-	return app.centeredView(content, Error)
+	return app.centeredView(content, app.theme.Error)
 }
 
 // centeredView creates a centered view with the given content and color
@@ -589,47 +1017,54 @@ func (app *App) centeredView(content string, color lipgloss.Color) string {
 
 // Error handling functions
 
-// classifyError creates an AppError with appropriate type and suggestions
-func (app *App) classifyError(err error) *AppError {
-	errStr := err.Error()
+// errorSuggestions are the suggestions shown for each type of error
+var errorSuggestions = map[ErrorType]string{
+	ErrorTypeNetwork:    "Check your network connection and try again.",
+	ErrorTypeAuth:       "Please run 'az login' to authenticate with Azure.",
+	ErrorTypePermission: "Check that your account has the required permissions.",
+	ErrorTypeConfig:     "Ensure Azure CLI is installed and in your PATH.",
+	ErrorTypeUnknown:    "An unexpected error occurred. Please try again.",
+}
 
-	switch {
-	case strings.Contains(errStr, "network") || strings.Contains(errStr, "connection") || strings.Contains(errStr, "timeout"):
-		return &AppError{
-			Err:        err,
-			Type:       ErrorTypeNetwork,
-			Retryable:  true,
-			Suggestion: "Check your network connection and try again.",
-		}
-	case strings.Contains(errStr, "authentication") || strings.Contains(errStr, "login"):
+// classifyError creates an AppError with appropriate type and suggestions,
+// from the typed errors in err's chain
+func (app *App) classifyError(err error) *AppError {
+	var challenge *ClaimsChallengeError
+	if errors.As(err, &challenge) {
 		return &AppError{
 			Err:        err,
 			Type:       ErrorTypeAuth,
 			Retryable:  false,
-			Suggestion: "Please run 'az login' to authenticate with Azure.",
-		}
-	case strings.Contains(errStr, "permission") || strings.Contains(errStr, "unauthorized"):
-		return &AppError{
-			Err:        err,
-			Type:       ErrorTypePermission,
-			Retryable:  false,
-			Suggestion: "Check that your account has the required permissions.",
-		}
-	case strings.Contains(errStr, "not found") || strings.Contains(errStr, "az"):
-		return &AppError{
-			Err:        err,
-			Type:       ErrorTypeConfig,
-			Retryable:  false,
-			Suggestion: "Ensure Azure CLI is installed and in your PATH.",
-		}
-	default:
-		return &AppError{
-			Err:        err,
-			Type:       ErrorTypeUnknown,
-			Retryable:  true,
-			Suggestion: "An unexpected error occurred. Please try again.",
+			Suggestion: fmt.Sprintf("Run 'az login --tenant %s --scope %s' to sign in with multi-factor authentication again.", challenge.TenantID, challenge.Scope),
 		}
 	}
+
+	errType := ErrorTypeUnknown
+	var cliErr *AzureCLIError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &cliErr):
+		errType = cliErr.Type
+	case errors.Is(err, ErrNetworkTimeout), errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		errType = ErrorTypeNetwork
+	case errors.Is(err, ErrUnauthorized), errors.Is(err, ErrProfileNotFound), errors.Is(err, ErrLoginFailed):
+		errType = ErrorTypeAuth
+	case errors.Is(err, ErrSubscriptionAccess), errors.Is(err, fs.ErrPermission):
+		errType = ErrorTypePermission
+	case errors.Is(err, ErrAzureCLINotFound), errors.Is(err, exec.ErrNotFound):
+		errType = ErrorTypeConfig
+	}
+
+	suggestion := errorSuggestions[errType]
+	if cliErr != nil && cliErr.Suggestion != "" {
+		suggestion = cliErr.Suggestion
+	}
+	return &AppError{
+		Err:        err,
+		Type:       errType,
+		Retryable:  errType == ErrorTypeNetwork || errType == ErrorTypeUnknown,
+		Suggestion: suggestion,
+	}
 }
 
 // shouldRetry checks if an operation should be retried
@@ -644,34 +1079,23 @@ func (app *App) shouldRetry(err error) bool {
 
 // retryOperation performs the retry with exponential backoff
 func (app *App) retryOperation() tea.Cmd {
-	delay := BaseDelay * time.Duration(1<<app.retryCount) // Exponential backoff
-	if delay > MaxDelay {
-		delay = MaxDelay
-	}
-
-	return tea.Tick(delay, func(t time.Time) tea.Msg {
+	return tea.Tick(app.retryDelay(), func(t time.Time) tea.Msg {
 		return RetryMsg{}
 	})
 }
 
-// Azure service functions
-
-// isAzureCLIAvailable checks if the Azure CLI is available
-func isAzureCLIAvailable() bool {
-	_, err := exec.LookPath(AzureCommand)
-	return err == nil
+// retryDelay returns the delay before the next retry, doubled on every
+// attempt up to the configured maximum
+func (app *App) retryDelay() time.Duration {
+	delay := app.config.BaseDelay * time.Duration(1<<app.retryCount) // Exponential backoff
+	return min(delay, app.config.MaxDelay)
 }
 
+// Subscription service functions
+
 // loadSubscriptions loads subscriptions asynchronously
 func (app *App) loadSubscriptions() tea.Msg {
-	if !isAzureCLIAvailable() {
-		return SubscriptionsLoadedMsg{
-			Subscriptions: nil,
-			Error:         ErrAzureCLINotFound,
-		}
-	}
-
-	data, err := fetchSubscriptionData()
+	subscriptions, err := app.provider.List()
 	if err != nil {
 		return SubscriptionsLoadedMsg{
 			Subscriptions: nil,
@@ -679,99 +1103,167 @@ func (app *App) loadSubscriptions() tea.Msg {
 		}
 	}
 
-	subscriptions, err := parseSubscriptions(data)
-	if err != nil {
-		return SubscriptionsLoadedMsg{
-			Subscriptions: nil,
-			Error:         fmt.Errorf("failed to parse subscription data: %w", err),
-		}
-	}
-
 	return SubscriptionsLoadedMsg{
 		Subscriptions: subscriptions,
+		ActiveCloud:   activeCloud(app.provider),
 		Error:         nil,
 	}
 }
 
-// fetchSubscriptionData retrieves raw subscription data
-func fetchSubscriptionData() ([]byte, error) {
-	if os.Getenv(EnvUseSampleData) == "true" {
-		return sampleData, nil
+// changeSubscription changes the active subscription
+func (app *App) changeSubscription(subscription Subscription) tea.Cmd {
+	// Read on the Update goroutine, which owns the app's state
+	activeID := app.selectedID
+	return func() tea.Msg {
+		// If it's already the selected subscription, no change is needed
+		result, err := switchSubscription(app.provider, subscription, activeID)
+		return SubscriptionChangedMsg{Changed: result.Changed, FromCloud: result.FromCloud, Error: err, Subscription: subscription}
 	}
+}
 
-	cmd := exec.Command(AzureCommand, azureAccountListArgs()...)
-	data, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("azure CLI command failed: %w", err)
-	}
+// main is the entry point of the application
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		var exitErr *ExitError
+		if errors.As(err, &exitErr) {
+			if exitErr.Err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", exitErr.Err)
+			}
+			os.Exit(exitErr.Code)
+		}
 
-	return data, nil
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(ExitCodeError)
+	}
 }
 
-func azureAccountListArgs() []string {
-	return []string{
-		"account",
-		"list",
-		"--all",
-		"--output",
-		"json",
-		"--query",
-		AzureListQuery,
+// run executes the main application logic
+func run(args []string) error {
+	flags := flag.NewFlagSet(AppName, flag.ContinueOnError)
+	flags.Usage = func() { printUsage(flags) }
+	configPath := flags.String("config", "", "configuration file (default $"+EnvXDGConfigHome+"/"+AppName+"/"+ConfigFileName+")")
+
+	// Settings from flags are applied once the configuration file is loaded
+	overrides := make(map[string]string)
+	for _, s := range settings {
+		flags.Func(s.Flag(), fmt.Sprintf("%s (env %s)", s.usage, s.Env()), func(value string) error {
+			overrides[s.key] = value
+			return nil
+		})
 	}
-}
 
-// parseSubscriptions parses JSON data into Subscription structs
-func parseSubscriptions(data []byte) ([]Subscription, error) {
-	var subscriptions []Subscription
-	if err := json.Unmarshal(data, &subscriptions); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
 	}
 
-	return subscriptions, nil
-}
+	config, err := loadUserConfig(*configPath, overrides, os.Stderr)
+	if err != nil {
+		return err
+	}
 
-// changeSubscription changes the active subscription
-func (app *App) changeSubscription(subscription Subscription) tea.Cmd {
-	return func() tea.Msg {
-		// If it's already the selected subscription, no change needed
-		if subscription.ID == app.selectedID {
-			return SubscriptionChangedMsg{Changed: false, Error: nil}
-		}
+	providerName := config.Provider
+	if command := flags.Arg(0); (command == CommandCurrent || command == CommandPrompt) &&
+		(providerName == ProviderAuto || providerName == "") {
+		// These run on every prompt render, where 'az account show' is too
+		// slow; without a profile there is no login to show anyway
+		providerName = ProviderNative
+	}
 
-		if !isAzureCLIAvailable() {
-			return SubscriptionChangedMsg{
-				Changed: false,
-				Error:   ErrAzureCLINotFound,
-			}
+	provider, err := newProvider(providerName, config.AzureCommand)
+	if err != nil {
+		return err
+	}
+	provider = config.withAliases(provider)
+
+	if flags.NArg() == 0 {
+		// Like 'env', the TUI is drawn on stderr so that the statements printed
+		// for the env key are all that goes to stdout
+		useRenderer(os.Stderr)
+		app := newUserApp(config, provider)
+		if err := runTUI(app, tea.WithOutput(os.Stderr)); err != nil {
+			return err
 		}
-
-		cmd := exec.Command(AzureCommand, "account", "set", "--subscription", subscription.ID)
-		if err := cmd.Run(); err != nil {
-			return SubscriptionChangedMsg{
-				Changed: false,
-				Error:   fmt.Errorf("failed to change subscription: %w", err),
-			}
+		if app.envSubscription != nil {
+			return writeEnv(os.Stdout, detectShell(), *app.envSubscription, false)
 		}
+		return nil
+	}
 
-		return SubscriptionChangedMsg{Changed: true, Error: nil, Subscription: subscription}
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case CommandToggle:
+		err = runToggle(provider, loadUserHistory(), os.Stdout)
+	case CommandConfig:
+		err = runConfig(config, commandArgs, os.Stdin, os.Stdout, os.Stderr)
+	case CommandSet:
+		err = runSet(config, provider, commandArgs, os.Stdout, os.Stderr)
+	case CommandList:
+		err = runList(provider, commandArgs, os.Stdout, os.Stderr)
+	case CommandCurrent:
+		err = runCurrent(provider, commandArgs, os.Stdout, os.Stderr)
+	case CommandPrompt:
+		err = runPrompt(config, provider, commandArgs, os.Stdout, os.Stderr)
+	case CommandHook:
+		err = runHook(provider, commandArgs, os.Stdout, os.Stderr)
+	case CommandShell:
+		err = runShell(config, commandArgs, os.Stdin, os.Stdout, os.Stderr)
+	case CommandRun:
+		err = runRun(config, commandArgs, os.Stdin, os.Stdout, os.Stderr)
+	case CommandFanout:
+		err = runFanout(config, provider, commandArgs, os.Stdout, os.Stderr)
+	case CommandEnv:
+		err = runEnv(config, provider, commandArgs, os.Stdout, os.Stderr)
+	default:
+		printUsage(flags)
+		return fmt.Errorf("%w: unknown command %q", ErrUsage, command)
 	}
-}
 
-// main is the entry point of the application
-func main() {
-	if err := run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(ExitCodeError)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
 	}
+	return err
 }
 
-// run executes the main application logic
-func run() error {
-	app := NewApp()
+// printUsage prints the top-level usage including the available subcommands
+func printUsage(flags *flag.FlagSet) {
+	out := flags.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command]\n\n", AppName)
+	fmt.Fprintln(out, "Commands:")
+	fmt.Fprintln(out, "  set <query>   switch to the subscription matching query")
+	fmt.Fprintln(out, "  -             switch back to the previously used subscription")
+	fmt.Fprintln(out, "  list          print subscriptions (--output json|table|tsv|names)")
+	fmt.Fprintln(out, "  current       print the active subscription (--format name|tenant/name|id|short|user|alias)")
+	fmt.Fprintln(out, "  prompt init <starship|ohmyposh|p10k|bash|zsh|fish>")
+	fmt.Fprintln(out, "                print prompt segment configuration")
+	fmt.Fprintln(out, "  hook <bash|zsh|fish>")
+	fmt.Fprintln(out, "                print a shell hook that follows "+PinFileName+" files")
+	fmt.Fprintln(out, "  shell [query] start $SHELL with its own default subscription")
+	fmt.Fprintln(out, "  run --sub <query> -- <command>")
+	fmt.Fprintln(out, "                run a command against a subscription without switching")
+	fmt.Fprintln(out, "  fanout -- <command>")
+	fmt.Fprintln(out, "                run a command against several selected subscriptions")
+	fmt.Fprintln(out, "  env [query]   print export statements, use as: eval \"$(asubselect env)\"")
+	fmt.Fprintln(out, "  config show|edit|validate")
+	fmt.Fprintln(out, "                print, edit or check the configuration file")
+	fmt.Fprintln(out, "\nFlags:")
+	flags.PrintDefaults()
+}
+
+// useRenderer makes lipgloss styles, and the background detection behind the
+// auto theme, target out rather than stdout. Call it before the app is built
+// when the TUI is drawn elsewhere.
+func useRenderer(out io.Writer) {
+	lipgloss.SetDefaultRenderer(lipgloss.NewRenderer(out))
+}
 
+// runTUI runs the interactive subscription selector
+func runTUI(app *App, options ...tea.ProgramOption) error {
 	program := tea.NewProgram(
 		app,
-		tea.WithAltScreen(),
+		append([]tea.ProgramOption{tea.WithAltScreen()}, options...)...,
 	)
 
 	if _, err := program.Run(); err != nil {
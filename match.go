@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// ErrNoMatch is returned when a query matches no subscription
+var ErrNoMatch = errors.New("no subscription matches")
+
+// resolveSubscriptions returns the subscriptions matching query, best first.
+// A configured alias wins outright, followed by exact (case-insensitive) ID
+// or name matches; otherwise ID prefixes are matched first, followed by the
+// same fuzzy matching the list filter applies to FilterValue.
+func resolveSubscriptions(subscriptions []Subscription, query string) []Subscription {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil
+	}
+
+	if exact := exactSubscriptions(subscriptions, query); len(exact) > 0 {
+		return exact
+	}
+
+	matched := make(map[int]bool)
+	var matches []Subscription
+	add := func(index int) {
+		if !matched[index] {
+			matched[index] = true
+			matches = append(matches, subscriptions[index])
+		}
+	}
+
+	lowerQuery := strings.ToLower(query)
+	for i, sub := range subscriptions {
+		if strings.HasPrefix(strings.ToLower(sub.ID), lowerQuery) {
+			add(i)
+		}
+	}
+
+	targets := make([]string, len(subscriptions))
+	for i, sub := range subscriptions {
+		targets[i] = sub.FilterValue()
+	}
+	for _, rank := range list.DefaultFilter(query, targets) {
+		add(rank.Index)
+	}
+
+	return matches
+}
+
+// exactSubscriptions returns the subscriptions whose alias, or else whose ID,
+// name or display name, equals query case-insensitively
+func exactSubscriptions(subscriptions []Subscription, query string) []Subscription {
+	query = strings.TrimSpace(query)
+	for _, sub := range subscriptions {
+		if sub.Alias != "" && strings.EqualFold(sub.Alias, query) {
+			return []Subscription{sub}
+		}
+	}
+
+	var exact []Subscription
+	for _, sub := range subscriptions {
+		if strings.EqualFold(sub.ID, query) || strings.EqualFold(sub.Name, query) ||
+			strings.EqualFold(sub.DisplayName, query) {
+			exact = append(exact, sub)
+		}
+	}
+	return exact
+}
+
+// resolveSubscription returns the single subscription matching query. When
+// several match, all of them are returned together with a nil error so the
+// caller can let the user choose.
+func resolveSubscription(subscriptions []Subscription, query string) (Subscription, []Subscription, error) {
+	matches := resolveSubscriptions(subscriptions, query)
+
+	switch len(matches) {
+	case 0:
+		return Subscription{}, nil, fmt.Errorf("%w %q", ErrNoMatch, query)
+	case 1:
+		return matches[0], matches, nil
+	default:
+		return Subscription{}, matches, nil
+	}
+}
+
+// subscriptionIDs returns the IDs of subscriptions, or nil when there are none
+func subscriptionIDs(subscriptions []Subscription) []string {
+	var ids []string
+	for _, sub := range subscriptions {
+		ids = append(ids, sub.ID)
+	}
+	return ids
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+var matchTestSubscriptions = []Subscription{
+	{ID: "3f50c9e1-8f3b-4d1e-9a2b-5b8e4b4e5f1a", Name: "Production", TenantDisplayName: "Contoso"},
+	{ID: "7d2e4c3a-6b9d-4f2e-8a3d-1c2e3b4d5f6a", Name: "Development", TenantDisplayName: "Contoso"},
+	{ID: "9a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d", Name: "Production", TenantDisplayName: "Fabrikam"},
+	{ID: "2b3c4d5e-6f7a-8b9c-0d1e-2f3a4b5c6d7e", Name: "Sandbox", TenantDisplayName: "Fabrikam"},
+}
+
+func TestResolveSubscriptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{name: "Exact ID", query: "7D2E4C3A-6B9D-4F2E-8A3D-1C2E3B4D5F6A", expected: []string{"7d2e4c3a-6b9d-4f2e-8a3d-1c2e3b4d5f6a"}},
+		{name: "ID prefix", query: "2b3c", expected: []string{"2b3c4d5e-6f7a-8b9c-0d1e-2f3a4b5c6d7e"}},
+		{name: "Exact name", query: "sandbox", expected: []string{"2b3c4d5e-6f7a-8b9c-0d1e-2f3a4b5c6d7e"}},
+		{name: "Duplicate names", query: "production", expected: []string{
+			"3f50c9e1-8f3b-4d1e-9a2b-5b8e4b4e5f1a",
+			"9a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d",
+		}},
+		{name: "Fuzzy name", query: "devel", expected: []string{"7d2e4c3a-6b9d-4f2e-8a3d-1c2e3b4d5f6a"}},
+		{name: "Fuzzy tenant", query: "fabrik", expected: []string{
+			"2b3c4d5e-6f7a-8b9c-0d1e-2f3a4b5c6d7e",
+			"9a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d",
+		}},
+		{name: "No match", query: "xyzzy", expected: nil},
+		{name: "Empty query", query: "  ", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := resolveSubscriptions(matchTestSubscriptions, tt.query)
+
+			if len(matches) != len(tt.expected) {
+				t.Fatalf("Expected %d matches, got %d: %v", len(tt.expected), len(matches), matches)
+			}
+
+			for i, id := range tt.expected {
+				if matches[i].ID != id {
+					t.Errorf("Expected match %d to be '%s', got '%s'", i, id, matches[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveSubscription(t *testing.T) {
+	sub, matches, err := resolveSubscription(matchTestSubscriptions, "sandbox")
+	if err != nil || sub.Name != "Sandbox" || len(matches) != 1 {
+		t.Errorf("Expected unique match 'Sandbox', got %v, %v, %v", sub, matches, err)
+	}
+
+	sub, matches, err = resolveSubscription(matchTestSubscriptions, "production")
+	if err != nil || sub.ID != "" || len(matches) != 2 {
+		t.Errorf("Expected two ambiguous matches, got %v, %v, %v", sub, matches, err)
+	}
+
+	if _, _, err := resolveSubscription(matchTestSubscriptions, "xyzzy"); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("Expected ErrNoMatch, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Directory pinning configuration
+const (
+	CommandHook = "hook"
+	hookApply   = "apply"
+
+	// PinFileName holds the subscription ID, name or alias for a directory tree
+	PinFileName = ".azuresub"
+
+	HookModeSwitch = "switch"
+	HookModeWarn   = "warn"
+)
+
+// ErrAmbiguousPin is returned when a pin file matches several subscriptions
+var ErrAmbiguousPin = errors.New("pinned subscription is ambiguous")
+
+var hookShells = []string{PromptBash, PromptZsh, PromptFish}
+
+// findPinFile walks up from dir looking for a pin file, returning its path
+// and the subscription query it contains, or empty strings if there is none
+func findPinFile(dir string) (string, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	for {
+		path := filepath.Join(dir, PinFileName)
+		query, err := readPinFile(path)
+		if err == nil {
+			return path, query, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", nil
+		}
+		dir = parent
+	}
+}
+
+// readPinFile returns the first non-empty, non-comment line of a pin file
+func readPinFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return "", fmt.Errorf("%s does not name a subscription", path)
+}
+
+// runHook implements 'asubselect hook <shell>' and the 'hook apply' command
+// the generated hooks call on every directory change
+func runHook(provider SubscriptionProvider, args []string, stdout, stderr io.Writer) error {
+	usage := fmt.Errorf("%w: %s %s <%s> [--mode switch|warn]", ErrUsage, AppName, CommandHook,
+		strings.Join(hookShells, "|"))
+	if len(args) == 0 {
+		return usage
+	}
+
+	target := args[0]
+	flags := newCommandFlags(CommandHook+" "+target, stderr)
+	mode := flags.String("mode", HookModeSwitch, "switch to the pinned subscription, or only warn")
+	dir := flags.String("dir", ".", "directory to start searching for "+PinFileName)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *mode != HookModeSwitch && *mode != HookModeWarn {
+		return fmt.Errorf("%w: unknown hook mode %q", ErrUsage, *mode)
+	}
+
+	switch target {
+	case hookApply:
+		return applyPin(provider, loadUserHistory(), *dir, *mode, stdout, stderr)
+	case PromptBash:
+		fmt.Fprintf(stdout, bashHookTemplate, AppName, *mode)
+	case PromptZsh:
+		fmt.Fprintf(stdout, zshHookTemplate, AppName, *mode)
+	case PromptFish:
+		fmt.Fprintf(stdout, fishHookTemplate, AppName, *mode)
+	default:
+		return usage
+	}
+
+	return nil
+}
+
+// applyPin switches to, or warns about, the subscription pinned for dir
+func applyPin(provider SubscriptionProvider, history *History, dir, mode string, stdout, stderr io.Writer) error {
+	path, query, err := findPinFile(dir)
+	if err != nil || path == "" {
+		return err
+	}
+
+	subscriptions, err := provider.List()
+	if err != nil {
+		return fmt.Errorf("failed to fetch subscription data: %w", err)
+	}
+
+	// Pins match exactly: a stale or mistyped pin must not switch to
+	// whatever it happens to fuzzy match on every directory change
+	matches := exactSubscriptions(subscriptions, query)
+	if len(matches) == 0 {
+		fmt.Fprintf(stderr, "%s: %s pins %q, which is not a subscription ID, name or alias; not switching\n",
+			AppName, path, query)
+		return nil
+	}
+	if len(matches) > 1 {
+		return fmt.Errorf("%s: %w: %q matches %d subscriptions", path, ErrAmbiguousPin, query, len(matches))
+	}
+	subscription := matches[0]
+
+	activeID := activeSubscriptionID(subscriptions, activeCloud(provider))
+	if mode == HookModeWarn {
+		if subscription.ID != activeID {
+			fmt.Fprintf(stderr, "%s: %s pins %q, run '%s set %s' to switch\n",
+				AppName, path, subscription.Title(), AppName, subscription.ID)
+		}
+		return nil
+	}
+
+	result, err := switchSubscription(provider, subscription, activeID)
+	if err != nil {
+		return err
+	}
+	if result.Changed {
+		if err := history.RecordSwitch(subscriptions, activeID, subscription, SourceHook); err != nil {
+			fmt.Fprintf(stderr, "Warning: %v\n", err)
+		}
+		printSwitchResult(stdout, result, subscription)
+	}
+
+	return nil
+}
+
+// Hook templates. %[1]s is the executable name and %[2]s the hook mode.
+const (
+	bashHookTemplate = `# Add to ~/.bashrc: eval "$(%[1]s hook bash)"
+__asubselect_hook() {
+  if [[ "$PWD" != "${__asubselect_last_pwd-}" ]]; then
+    __asubselect_last_pwd=$PWD
+    %[1]s hook apply --mode %[2]s
+  fi
+}
+case ";${PROMPT_COMMAND-};" in
+  *";__asubselect_hook;"*) ;;
+  *) PROMPT_COMMAND="__asubselect_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
+esac
+`
+
+	zshHookTemplate = `# Add to ~/.zshrc: eval "$(%[1]s hook zsh)"
+__asubselect_hook() {
+  %[1]s hook apply --mode %[2]s
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook chpwd __asubselect_hook
+__asubselect_hook
+`
+
+	fishHookTemplate = `# Add to ~/.config/fish/config.fish: %[1]s hook fish | source
+function __asubselect_hook --on-variable PWD
+    %[1]s hook apply --mode %[2]s
+end
+__asubselect_hook
+`
+)
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePinFile(t *testing.T, dir, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, PinFileName), []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write pin file: %v", err)
+	}
+}
+
+func TestFindPinFile(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+
+	path, query, err := findPinFile(nested)
+	if err != nil || path != "" || query != "" {
+		t.Errorf("Expected no pin file, got %q, %q, %v", path, query, err)
+	}
+
+	writePinFile(t, root, "# platform team\n\n  the Sub  \n")
+
+	path, query, err = findPinFile(nested)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if path != filepath.Join(root, PinFileName) || query != "the Sub" {
+		t.Errorf("Expected pin 'the Sub' in root, got %q, %q", path, query)
+	}
+}
+
+func TestFindPinFile_Empty(t *testing.T) {
+	dir := t.TempDir()
+	writePinFile(t, dir, "# nothing here\n")
+
+	if _, _, err := findPinFile(dir); err == nil {
+		t.Error("Expected error for pin file without a subscription")
+	}
+}
+
+func TestApplyPin_Switch(t *testing.T) {
+	dir := t.TempDir()
+	writePinFile(t, dir, "8e9f0a1b-2c3d-4e5f-6a7b-8c9d0e1f2a3b\n")
+	provider := NewSampleProvider()
+	history := &History{}
+
+	var out bytes.Buffer
+	if err := applyPin(provider, history, dir, HookModeSwitch, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !strings.Contains(out.String(), SuccessMessage) {
+		t.Errorf("Expected success message, got: %s", out.String())
+	}
+
+	// The subscription switched away from is recorded too, so 'asubselect -' returns to it
+	if entries := history.Entries(); len(entries) != 2 || entries[0].Source != SourceHook ||
+		entries[0].SubscriptionID != "8e9f0a1b-2c3d-4e5f-6a7b-8c9d0e1f2a3b" || entries[1].SubscriptionID != "3f50c9e1-8f3b-4d1e-9a2b-5b8e4b4e5f1a" {
+		t.Errorf("Expected the switch to be recorded as a hook switch, got %+v", entries)
+	}
+
+	out.Reset()
+	if err := applyPin(provider, history, dir, HookModeSwitch, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("Expected no output when no change is needed, got: %s", out.String())
+	}
+
+	if len(history.Entries()) != 2 {
+		t.Errorf("Expected no history entry when no change is needed, got %d", len(history.Entries()))
+	}
+}
+
+func TestApplyPin_Warn(t *testing.T) {
+	dir := t.TempDir()
+	writePinFile(t, dir, "the Sub")
+	provider := NewSampleProvider()
+
+	var stderr bytes.Buffer
+	if err := applyPin(provider, &History{}, dir, HookModeWarn, io.Discard, &stderr); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !strings.Contains(stderr.String(), "Fabrikam / the Sub") {
+		t.Errorf("Expected warning about pinned subscription, got: %s", stderr.String())
+	}
+
+	if current, _ := provider.Current(); current.Name != "Subscription 1" {
+		t.Errorf("Expected warn mode not to switch, got '%s'", current.Name)
+	}
+}
+
+func TestApplyPin_Ambiguous(t *testing.T) {
+	dir := t.TempDir()
+	writePinFile(t, dir, "Shared")
+	provider := NewFakeProvider([]Subscription{
+		{ID: "sub-1", Name: "Shared", IsDefault: true},
+		{ID: "sub-2", Name: "Shared"},
+	})
+
+	err := applyPin(provider, &History{}, dir, HookModeSwitch, io.Discard, io.Discard)
+	if !errors.Is(err, ErrAmbiguousPin) {
+		t.Errorf("Expected ErrAmbiguousPin, got %v", err)
+	}
+}
+
+func TestApplyPin_NoExactMatch(t *testing.T) {
+	// Prefixes and fuzzy matches, as 'set' accepts, never switch from a pin
+	for _, pin := range []string{"Contoso", "8e9f0a1b", "the Su"} {
+		t.Run(pin, func(t *testing.T) {
+			dir := t.TempDir()
+			writePinFile(t, dir, pin)
+			provider := NewSampleProvider()
+			history := &History{}
+
+			var stdout, stderr bytes.Buffer
+			if err := applyPin(provider, history, dir, HookModeSwitch, &stdout, &stderr); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !strings.Contains(stderr.String(), "not switching") || stdout.Len() != 0 {
+				t.Errorf("Expected only a warning, got %q and %q", stdout.String(), stderr.String())
+			}
+			if current, _ := provider.Current(); current.Name != "Subscription 1" || len(history.Entries()) != 0 {
+				t.Errorf("Expected no switch, got '%s'", current.Name)
+			}
+		})
+	}
+}
+
+func TestRunHook_Scripts(t *testing.T) {
+	for _, shell := range hookShells {
+		var out bytes.Buffer
+		if err := runHook(NewSampleProvider(), []string{shell, "--mode", HookModeWarn}, &out, io.Discard); err != nil {
+			t.Fatalf("Expected no error for %s, got: %v", shell, err)
+		}
+
+		if !strings.Contains(out.String(), "hook apply --mode warn") {
+			t.Errorf("Expected %s hook to call 'hook apply', got: %s", shell, out.String())
+		}
+	}
+
+	if err := runHook(NewSampleProvider(), []string{PromptBash, "--mode", "bogus"}, io.Discard, io.Discard); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage, got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Azure CLI profile configuration
+const (
+	EnvAzureConfigDir      = "AZURE_CONFIG_DIR"
+	AzureDefaultConfigDir  = ".azure"
+	AzureProfileFileName   = "azureProfile.json"
+	azureProfileSubsKey    = "subscriptions"
+	azureProfileDefaultKey = "isDefault"
+	azureProfileCloudKey   = "environmentName"
+)
+
+// ErrProfileNotFound is returned when the Azure CLI profile does not exist
+var ErrProfileNotFound = errors.New("azure profile not found - run 'az login'")
+
+// utf8BOM is written by the Azure CLI at the start of its JSON files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// azureConfigDir returns the Azure CLI configuration directory
+func azureConfigDir() (string, error) {
+	if dir := os.Getenv(EnvAzureConfigDir); dir != "" {
+		return dir, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+
+	return filepath.Join(home, AzureDefaultConfigDir), nil
+}
+
+// azureProfilePath returns the path of azureProfile.json
+func azureProfilePath() (string, error) {
+	dir, err := azureConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, AzureProfileFileName), nil
+}
+
+// NativeProvider implements SubscriptionProvider by reading and writing
+// azureProfile.json directly, without spawning the Azure CLI
+type NativeProvider struct {
+	path string
+}
+
+// NewNativeProvider creates a provider for the profile at the given path
+func NewNativeProvider(path string) *NativeProvider {
+	return &NativeProvider{path: path}
+}
+
+// List implements SubscriptionProvider
+func (p *NativeProvider) List() ([]Subscription, error) {
+	data, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+
+	// The profile names the cloud environmentName, 'az account list' cloudName
+	var profile struct {
+		Subscriptions []struct {
+			Subscription
+			EnvironmentName string `json:"environmentName"`
+		} `json:"subscriptions"`
+	}
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", p.path, err)
+	}
+
+	subscriptions := make([]Subscription, len(profile.Subscriptions))
+	for i, entry := range profile.Subscriptions {
+		subscriptions[i] = entry.Subscription
+		subscriptions[i].CloudName = cmp.Or(entry.CloudName, entry.EnvironmentName)
+	}
+	return subscriptions, nil
+}
+
+// Current implements SubscriptionProvider
+func (p *NativeProvider) Current() (Subscription, error) {
+	subscriptions, err := p.List()
+	if err != nil {
+		return Subscription{}, err
+	}
+
+	cloud, err := p.ActiveCloud()
+	if err != nil {
+		return Subscription{}, err
+	}
+
+	index := findActiveSubscription(subscriptions, cloud)
+	if index < 0 {
+		return Subscription{}, ErrNoDefaultSubscription
+	}
+
+	return subscriptions[index], nil
+}
+
+// SetDefault implements SubscriptionProvider. Like 'az account set', it
+// rewrites the isDefault flags so that only the given subscription is set in
+// its cloud, preserving every other field in the profile.
+func (p *NativeProvider) SetDefault(subscription Subscription) error {
+	data, err := p.read()
+	if err != nil {
+		return err
+	}
+
+	var profile map[string]json.RawMessage
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", p.path, err)
+	}
+
+	var entries []map[string]json.RawMessage
+	if err := json.Unmarshal(profile[azureProfileSubsKey], &entries); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", p.path, err)
+	}
+
+	index := slices.IndexFunc(entries, func(entry map[string]json.RawMessage) bool {
+		return profileEntryID(entry) == subscription.ID
+	})
+	if index < 0 {
+		return fmt.Errorf("subscription %q not found in %s", subscription.ID, p.path)
+	}
+
+	// Each cloud has its own default
+	cloud := profileEntryString(entries[index], azureProfileCloudKey)
+	for _, entry := range entries {
+		if profileEntryString(entry, azureProfileCloudKey) != cloud {
+			continue
+		}
+		entry[azureProfileDefaultKey] = json.RawMessage("false")
+		if profileEntryID(entry) == subscription.ID {
+			entry[azureProfileDefaultKey] = json.RawMessage("true")
+		}
+	}
+
+	if profile[azureProfileSubsKey], err = json.Marshal(entries); err != nil {
+		return fmt.Errorf("failed to encode subscriptions: %w", err)
+	}
+
+	out, err := json.MarshalIndent(profile, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to encode profile: %w", err)
+	}
+
+	if err := writeFileAtomic(p.path, append(slices.Clone(utf8BOM), out...)); err != nil {
+		return err
+	}
+
+	// Like 'az account set', record the cloud's default in clouds.config too
+	return updateINIFile(p.cloudsPath(), cmp.Or(cloud, AzurePublicCloud), azureCloudSubscriptionKey, subscription.ID)
+}
+
+// read returns the profile contents with any byte order mark removed
+func (p *NativeProvider) read() ([]byte, error) {
+	data, err := os.ReadFile(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrProfileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p.path, err)
+	}
+
+	return bytes.TrimPrefix(data, utf8BOM), nil
+}
+
+// profileEntryID extracts the subscription ID from a raw profile entry
+func profileEntryID(entry map[string]json.RawMessage) string {
+	return profileEntryString(entry, "id")
+}
+
+// profileEntryString extracts a string field from a raw profile entry
+func profileEntryString(entry map[string]json.RawMessage, key string) string {
+	var value string
+	_ = json.Unmarshal(entry[key], &value)
+	return value
+}
+
+// writeFileAtomic replaces path with data via a temporary file and rename,
+// so concurrent readers never observe a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+
+	return nil
+}
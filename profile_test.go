@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testAzureProfile = `{
+	"installationId": "c7a1b2d3-0000-0000-0000-000000000000",
+	"subscriptions": [
+		{
+			"id": "sub-1",
+			"name": "Sub 1",
+			"state": "Enabled",
+			"user": {"name": "me@example.com", "type": "user"},
+			"isDefault": true,
+			"tenantId": "tenant-1",
+			"environmentName": "AzureCloud",
+			"tenantDisplayName": "Contoso"
+		},
+		{
+			"id": "sub-2",
+			"name": "Sub 2",
+			"state": "Enabled",
+			"user": {"name": "me@example.com", "type": "user"},
+			"isDefault": false,
+			"tenantId": "tenant-2",
+			"environmentName": "AzureCloud",
+			"tenantDisplayName": "Fabrikam"
+		}
+	]
+}`
+
+// writeTestProfile writes an azureProfile.json with a BOM, as the Azure CLI does
+func writeTestProfile(t *testing.T, dir string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, AzureProfileFileName)
+	data := append(append([]byte{}, utf8BOM...), testAzureProfile...)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write test profile: %v", err)
+	}
+
+	return path
+}
+
+func TestNativeProvider_List(t *testing.T) {
+	provider := NewNativeProvider(writeTestProfile(t, t.TempDir()))
+
+	subs, err := provider.List()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(subs) != 2 {
+		t.Fatalf("Expected 2 subscriptions, got %d", len(subs))
+	}
+
+	if subs[0].Title() != "Contoso / Sub 1" || !subs[0].IsDefault {
+		t.Errorf("Unexpected first subscription: %+v", subs[0])
+	}
+
+	if subs[1].User.Name != "me@example.com" {
+		t.Errorf("Expected user 'me@example.com', got '%s'", subs[1].User.Name)
+	}
+}
+
+func TestNativeProvider_SetDefault(t *testing.T) {
+	path := writeTestProfile(t, t.TempDir())
+	provider := NewNativeProvider(path)
+
+	if err := provider.SetDefault(Subscription{ID: "sub-2"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	current, err := provider.Current()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if current.ID != "sub-2" {
+		t.Errorf("Expected current subscription 'sub-2', got '%s'", current.ID)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read profile: %v", err)
+	}
+
+	if !bytes.HasPrefix(data, utf8BOM) {
+		t.Error("Expected profile to keep its byte order mark")
+	}
+
+	var profile struct {
+		InstallationID string           `json:"installationId"`
+		Subscriptions  []map[string]any `json:"subscriptions"`
+	}
+	if err := json.Unmarshal(bytes.TrimPrefix(data, utf8BOM), &profile); err != nil {
+		t.Fatalf("Failed to parse rewritten profile: %v", err)
+	}
+
+	if profile.InstallationID == "" {
+		t.Error("Expected installationId to be preserved")
+	}
+
+	if profile.Subscriptions[0]["isDefault"] != false || profile.Subscriptions[1]["isDefault"] != true {
+		t.Errorf("Expected only sub-2 to be default, got %v", profile.Subscriptions)
+	}
+
+	if profile.Subscriptions[0]["tenantId"] != "tenant-1" {
+		t.Error("Expected unknown subscription fields to be preserved")
+	}
+
+	// Like 'az account set', the cloud's default is kept in clouds.config too
+	clouds, _ := os.ReadFile(filepath.Join(filepath.Dir(path), AzureCloudsFileName))
+	if string(clouds) != "[AzureCloud]\nsubscription = sub-2\n" {
+		t.Errorf("Expected the default in clouds.config, got:\n%s", clouds)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 2 {
+		t.Errorf("Expected no temporary files to be left behind, got %d entries", len(entries))
+	}
+}
+
+func TestNativeProvider_SetDefault_CloudsConfig(t *testing.T) {
+	dir := t.TempDir()
+	provider := NewNativeProvider(writeTestProfile(t, dir))
+	clouds := "[AzureCloud]\nsubscription = sub-1\n\n[AzureStackUser]\nprofile = 2020-09-01-hybrid\nendpoint_resource_manager = https://management.local.azurestack.external\n"
+	if err := os.WriteFile(filepath.Join(dir, AzureCloudsFileName), []byte(clouds), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := provider.SetDefault(Subscription{ID: "sub-2"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := strings.Replace(clouds, "subscription = sub-1", "subscription = sub-2", 1)
+	if data, _ := os.ReadFile(filepath.Join(dir, AzureCloudsFileName)); string(data) != expected {
+		t.Errorf("Expected only the AzureCloud default updated, got:\n%s", data)
+	}
+}
+
+func TestNativeProvider_SetDefault_UnknownSubscription(t *testing.T) {
+	provider := NewNativeProvider(writeTestProfile(t, t.TempDir()))
+
+	if err := provider.SetDefault(Subscription{ID: "missing"}); err == nil {
+		t.Error("Expected error for unknown subscription, got nil")
+	}
+
+	if current, _ := provider.Current(); current.ID != "sub-1" {
+		t.Errorf("Expected default to be unchanged, got '%s'", current.ID)
+	}
+}
+
+func TestNativeProvider_MissingProfile(t *testing.T) {
+	provider := NewNativeProvider(filepath.Join(t.TempDir(), AzureProfileFileName))
+
+	if _, err := provider.List(); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected ErrProfileNotFound, got %v", err)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(EnvAzureConfigDir, dir)
+	t.Setenv(EnvUseSampleData, "")
+
+	tests := []struct {
+		name     string
+		setup    func()
+		expected SubscriptionProvider
+	}{
+		{name: ProviderAzureCLI, expected: &AzureCLIProvider{Command: AzureCommand}},
+		{name: ProviderNative, expected: &NativeProvider{}},
+		{name: ProviderSample, expected: &FakeProvider{}},
+		{name: ProviderAuto, expected: &AzureCLIProvider{Command: AzureCommand}},
+		{name: ProviderAuto, setup: func() { writeTestProfile(t, dir) }, expected: &NativeProvider{}},
+	}
+
+	for _, tt := range tests {
+		if tt.setup != nil {
+			tt.setup()
+		}
+
+		provider, err := newProvider(tt.name, AzureCommand)
+		if err != nil {
+			t.Fatalf("Expected no error for %q, got: %v", tt.name, err)
+		}
+
+		if got, want := fmt.Sprintf("%T", provider), fmt.Sprintf("%T", tt.expected); got != want {
+			t.Errorf("Expected %s for %q, got %s", want, tt.name, got)
+		}
+	}
+
+	if _, err := newProvider("bogus", AzureCommand); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("Expected ErrUnknownProvider, got %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Prompt subcommands and targets
+const (
+	CommandPrompt = "prompt"
+	promptInit    = "init"
+	promptSegment = "segment"
+
+	PromptStarship = "starship"
+	PromptOhMyPosh = "ohmyposh"
+	PromptP10k     = "p10k"
+	PromptBash     = "bash"
+	PromptZsh      = "zsh"
+	PromptFish     = "fish"
+
+	// Segment kinds, used to style production subscriptions separately
+	SegmentAll        = "all"
+	SegmentNormal     = "normal"
+	SegmentProduction = "production"
+)
+
+// productionPattern matches subscription names that denote production,
+// without matching "nonprod" or "preprod"
+var (
+	productionPattern    = regexp.MustCompile(`(?i)(^|[^a-z])prod(uction)?([^a-z]|$)`)
+	nonProductionPattern = regexp.MustCompile(`(?i)(non|pre)[-_ ]?prod`)
+)
+
+// isProductionSubscription reports whether a subscription looks like production
+func isProductionSubscription(subscription Subscription) bool {
+	return productionPattern.MatchString(subscription.Name) &&
+		!nonProductionPattern.MatchString(subscription.Name)
+}
+
+// runPrompt implements 'asubselect prompt init|segment'. Segments use the
+// configured theme's info color, and its error color for production.
+func runPrompt(config *Config, provider SubscriptionProvider, args []string, stdout, stderr io.Writer) error {
+	usage := fmt.Errorf("%w: %s %s init <%s> | segment [flags]", ErrUsage, AppName, CommandPrompt,
+		strings.Join(promptTargets, "|"))
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch args[0] {
+	case promptInit:
+		if len(args) != 2 {
+			return usage
+		}
+		return writePromptInit(stdout, args[1], config.ActiveTheme())
+	case promptSegment:
+		return runPromptSegment(config, provider, args[1:], stdout, stderr)
+	default:
+		return usage
+	}
+}
+
+var promptTargets = []string{PromptStarship, PromptOhMyPosh, PromptP10k, PromptBash, PromptZsh, PromptFish}
+
+// runPromptSegment prints the colored segment text for the active subscription.
+// It prints nothing and exits non-zero when the subscription does not match
+// --kind, so prompt frameworks hide the segment.
+func runPromptSegment(config *Config, provider SubscriptionProvider, args []string, stdout, stderr io.Writer) error {
+	flags := newCommandFlags(CommandPrompt+" "+promptSegment, stderr)
+	format := flags.String("format", FormatName, "segment text format, as for 'current --format'")
+	shell := flags.String("shell", "", "emit ANSI colors escaped for this shell: bash, zsh or fish")
+	kind := flags.String("kind", SegmentAll, "only print for all, normal or production subscriptions")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	subscription, err := provider.Current()
+	if isNotLoggedIn(err) {
+		return &ExitError{Code: ExitCodeNotLoggedIn}
+	}
+	if err != nil {
+		return err
+	}
+
+	production := isProductionSubscription(subscription)
+	switch *kind {
+	case SegmentAll:
+	case SegmentNormal, SegmentProduction:
+		if production != (*kind == SegmentProduction) {
+			return &ExitError{Code: ExitCodeError}
+		}
+	default:
+		return fmt.Errorf("%w: unknown segment kind %q", ErrUsage, *kind)
+	}
+
+	text, err := formatSubscription(subscription, *format)
+	if err != nil {
+		return err
+	}
+
+	if *shell == "" {
+		fmt.Fprintln(stdout, text)
+		return nil
+	}
+
+	theme := config.ActiveTheme()
+	color, bold := theme.Info, false
+	if production {
+		color, bold = theme.Error, true
+	}
+
+	segment, err := colorizeForShell(*shell, text, color, bold)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(stdout, segment)
+	return nil
+}
+
+// colorizeForShell wraps text in truecolor ANSI sequences, marked as
+// zero-width for the given shell's prompt
+func colorizeForShell(shell, text string, color lipgloss.Color, bold bool) (string, error) {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(string(color), "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return "", fmt.Errorf("invalid color %q: %w", color, err)
+	}
+
+	start := fmt.Sprintf("\x1b[38;2;%d;%d;%dm", r, g, b)
+	if bold {
+		start = "\x1b[1m" + start
+	}
+	end := "\x1b[0m"
+
+	switch shell {
+	case PromptBash:
+		// readline's RL_PROMPT_START_IGNORE / RL_PROMPT_END_IGNORE markers
+		return "\x01" + start + "\x02" + text + "\x01" + end + "\x02", nil
+	case PromptZsh:
+		return "%{" + start + "%}" + strings.ReplaceAll(text, "%", "%%") + "%{" + end + "%}", nil
+	case PromptFish:
+		return start + text + end, nil
+	default:
+		return "", fmt.Errorf("%w: unknown shell %q", ErrUsage, shell)
+	}
+}
+
+// writePromptInit writes the prompt configuration for the given target, in
+// the theme's colors
+func writePromptInit(out io.Writer, target string, theme Theme) error {
+	normal := strings.TrimPrefix(string(theme.Info), "#")
+	production := strings.TrimPrefix(string(theme.Error), "#")
+
+	switch target {
+	case PromptStarship:
+		fmt.Fprintf(out, starshipTemplate, AppName, normal, production)
+	case PromptOhMyPosh:
+		fmt.Fprintf(out, ohMyPoshTemplate, AppName, normal, production)
+	case PromptP10k:
+		fmt.Fprintf(out, p10kTemplate, AppName, normal, production)
+	case PromptBash:
+		fmt.Fprintf(out, bashPromptTemplate, AppName)
+	case PromptZsh:
+		fmt.Fprintf(out, zshPromptTemplate, AppName)
+	case PromptFish:
+		fmt.Fprintf(out, fishPromptTemplate, AppName)
+	default:
+		return fmt.Errorf("%w: unknown prompt target %q", ErrUsage, target)
+	}
+
+	return nil
+}
+
+// Prompt templates. %[1]s is the executable name, %[2]s and %[3]s are the
+// normal and production colors without the leading '#'. Starship hides custom
+// modules whose command prints nothing, so `when` is always true rather than
+// a second run of the segment. Each of the two modules still runs it once.
+const (
+	starshipTemplate = `# Add to ~/.config/starship.toml
+[custom.azure_subscription]
+command = "%[1]s prompt segment --kind normal"
+when = true
+style = "fg:#%[2]s"
+format = "[$output]($style) "
+
+[custom.azure_subscription_production]
+command = "%[1]s prompt segment --kind production"
+when = true
+style = "bold fg:#%[3]s"
+format = "[$output]($style) "
+`
+
+	ohMyPoshTemplate = `// Add to the segments of a block in your oh-my-posh config
+{
+  "type": "command",
+  "style": "plain",
+  "foreground": "#%[2]s",
+  "template": "{{ .Output }} ",
+  "properties": {
+    "shell": "sh",
+    "command": "%[1]s prompt segment --kind normal"
+  }
+},
+{
+  "type": "command",
+  "style": "plain",
+  "foreground": "#%[3]s",
+  "template": "<b>{{ .Output }}</b> ",
+  "properties": {
+    "shell": "sh",
+    "command": "%[1]s prompt segment --kind production"
+  }
+}
+`
+
+	p10kTemplate = `# Add to ~/.p10k.zsh and append 'asubselect' to
+# POWERLEVEL9K_LEFT_PROMPT_ELEMENTS or POWERLEVEL9K_RIGHT_PROMPT_ELEMENTS
+function prompt_asubselect() {
+  local name
+  if name=$(%[1]s prompt segment --kind production 2>/dev/null); then
+    p10k segment -b '' -f '#%[3]s' -t "%%B${name//\%%/%%%%}%%b"
+  elif name=$(%[1]s prompt segment --kind normal 2>/dev/null); then
+    p10k segment -b '' -f '#%[2]s' -t "${name//\%%/%%%%}"
+  fi
+}
+`
+
+	bashPromptTemplate = `# Add to ~/.bashrc: eval "$(%[1]s prompt init bash)"
+__asubselect_prompt() {
+  local segment
+  segment=$(%[1]s prompt segment --shell bash 2>/dev/null) && printf '%%s ' "$segment"
+}
+case "$PS1" in
+  *__asubselect_prompt*) ;;
+  *) PS1='$(__asubselect_prompt)'"$PS1" ;;
+esac
+`
+
+	zshPromptTemplate = `# Add to ~/.zshrc: eval "$(%[1]s prompt init zsh)"
+setopt prompt_subst
+__asubselect_prompt() {
+  local segment
+  segment=$(%[1]s prompt segment --shell zsh 2>/dev/null) && print -rn -- "$segment "
+}
+if [[ $PROMPT != *__asubselect_prompt* ]]; then
+  PROMPT='$(__asubselect_prompt)'"$PROMPT"
+fi
+`
+
+	fishPromptTemplate = `# Add to ~/.config/fish/config.fish: %[1]s prompt init fish | source
+function __asubselect_prompt
+    set -l segment (%[1]s prompt segment --shell fish 2>/dev/null)
+    and printf '%%s ' $segment
+end
+if not functions -q __asubselect_original_prompt
+    functions -c fish_prompt __asubselect_original_prompt
+    function fish_prompt
+        __asubselect_prompt
+        __asubselect_original_prompt
+    end
+end
+`
+)
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestIsProductionSubscription(t *testing.T) {
+	tests := map[string]bool{
+		"Production":                      true,
+		"contoso-prod-we":                 true,
+		"PROD":                            true,
+		"CONTOSO-PLATFORM-NONPROD-WE-001": false,
+		"contoso-non-prod":                false,
+		"preprod":                         false,
+		"Product Team Sandbox":            false,
+		"Development":                     false,
+	}
+
+	for name, expected := range tests {
+		if got := isProductionSubscription(Subscription{Name: name}); got != expected {
+			t.Errorf("Expected isProductionSubscription(%q) to be %v, got %v", name, expected, got)
+		}
+	}
+}
+
+func TestColorizeForShell(t *testing.T) {
+	bash, err := colorizeForShell(PromptBash, "Sub", "#f38ba8", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if bash != "\x01\x1b[38;2;243;139;168m\x02Sub\x01\x1b[0m\x02" {
+		t.Errorf("Unexpected bash segment: %q", bash)
+	}
+
+	zsh, _ := colorizeForShell(PromptZsh, "100%", Info, true)
+	if !strings.HasPrefix(zsh, "%{\x1b[1m") || !strings.Contains(zsh, "100%%") {
+		t.Errorf("Unexpected zsh segment: %q", zsh)
+	}
+
+	if _, err := colorizeForShell("tcsh", "Sub", Info, false); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage, got %v", err)
+	}
+}
+
+func TestRunPromptSegment_Kind(t *testing.T) {
+	provider := NewFakeProvider([]Subscription{{ID: "sub-1", Name: "contoso-prod", IsDefault: true}})
+
+	var out bytes.Buffer
+	if err := runPrompt(DefaultConfig(), provider, []string{promptSegment, "--kind", SegmentProduction}, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if out.String() != "contoso-prod\n" {
+		t.Errorf("Expected production segment, got %q", out.String())
+	}
+
+	out.Reset()
+	err := runPrompt(DefaultConfig(), provider, []string{promptSegment, "--kind", SegmentNormal}, &out, io.Discard)
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || out.Len() != 0 {
+		t.Errorf("Expected silent failure for non-matching kind, got %v and %q", err, out.String())
+	}
+}
+
+func TestRunPromptSegment_Theme(t *testing.T) {
+	provider := NewFakeProvider([]Subscription{{ID: "sub-1", Name: "contoso-prod", IsDefault: true}})
+	config := DefaultConfig()
+	config.Theme = FlavorLatte
+
+	var out bytes.Buffer
+	if err := runPrompt(config, provider, []string{promptSegment, "--shell", PromptFish}, &out, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected, _ := colorizeForShell(PromptFish, "contoso-prod", LattePalette.Red, true)
+	if out.String() != expected {
+		t.Errorf("Expected the segment in the theme's error color, got %q", out.String())
+	}
+}
+
+func TestRunPromptSegment_NotLoggedIn(t *testing.T) {
+	err := runPrompt(DefaultConfig(), NewFakeProvider(nil), []string{promptSegment}, io.Discard, io.Discard)
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != ExitCodeNotLoggedIn || exitErr.Err != nil {
+		t.Errorf("Expected silent exit code %d, got %v", ExitCodeNotLoggedIn, err)
+	}
+}
+
+func TestRunPromptInit(t *testing.T) {
+	for _, target := range promptTargets {
+		var out bytes.Buffer
+		if err := runPrompt(DefaultConfig(), NewSampleProvider(), []string{promptInit, target}, &out, io.Discard); err != nil {
+			t.Fatalf("Expected no error for %s, got: %v", target, err)
+		}
+
+		if !strings.Contains(out.String(), AppName+" prompt segment") {
+			t.Errorf("Expected %s config to call the segment command, got: %s", target, out.String())
+		}
+
+		if strings.Contains(out.String(), "%!") {
+			t.Errorf("Expected %s config to be formatted cleanly, got: %s", target, out.String())
+		}
+	}
+
+	config := DefaultConfig()
+	config.Theme = FlavorLatte
+	var out bytes.Buffer
+	runPrompt(config, NewSampleProvider(), []string{promptInit, PromptStarship}, &out, io.Discard)
+	if !strings.Contains(out.String(), strings.TrimPrefix(string(LattePalette.Red), "#")) {
+		t.Errorf("Expected starship config to use the theme's error color, got: %s", out.String())
+	}
+	if count := strings.Count(out.String(), "prompt segment"); count != 2 {
+		t.Errorf("Expected the segment run once per starship module, got %d runs", count)
+	}
+
+	if err := runPrompt(DefaultConfig(), NewSampleProvider(), []string{promptInit, "tcsh"}, io.Discard, io.Discard); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage, got %v", err)
+	}
+}
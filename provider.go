@@ -0,0 +1,315 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Provider names accepted by --provider and ASUBSELECT_PROVIDER
+const (
+	ProviderAuto     = "auto"
+	ProviderNative   = "native"
+	ProviderAzureCLI = "az"
+	ProviderSample   = "sample"
+)
+
+var providerNames = []string{ProviderAuto, ProviderNative, ProviderAzureCLI, ProviderSample}
+
+// Provider errors
+var (
+	ErrNoDefaultSubscription = errors.New("no default subscription set")
+	ErrUnknownProvider       = errors.New("unknown subscription provider")
+)
+
+// SubscriptionProvider is the backend used to enumerate and switch subscriptions
+type SubscriptionProvider interface {
+	// List returns all subscriptions known to the backend
+	List() ([]Subscription, error)
+	// SetDefault makes the given subscription the active default
+	SetDefault(subscription Subscription) error
+	// Current returns the active default subscription
+	Current() (Subscription, error)
+}
+
+// newProvider creates the named provider, using azCommand for the Azure CLI.
+// USE_SAMPLE_DATA overrides the name, and "auto" reads azureProfile.json
+// natively when it exists, falling back to the Azure CLI otherwise.
+func newProvider(name, azCommand string) (SubscriptionProvider, error) {
+	if os.Getenv(EnvUseSampleData) == "true" {
+		name = ProviderSample
+	}
+
+	switch name {
+	case ProviderSample:
+		return NewSampleProvider(), nil
+	case ProviderAzureCLI:
+		return NewAzureCLIProvider(azCommand), nil
+	case ProviderNative, ProviderAuto, "":
+		path, err := azureProfilePath()
+		if err != nil {
+			return nil, err
+		}
+		if name == ProviderNative {
+			return NewNativeProvider(path), nil
+		}
+		if _, err := os.Stat(path); err == nil {
+			return NewNativeProvider(path), nil
+		}
+		return NewAzureCLIProvider(azCommand), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+	}
+}
+
+// providerAs returns the provider, or the provider it wraps, as a T. This
+// finds optional capabilities, like switching clouds, behind the alias provider.
+func providerAs[T any](provider SubscriptionProvider) (T, bool) {
+	for provider != nil {
+		if p, ok := provider.(T); ok {
+			return p, true
+		}
+		wrapper, ok := provider.(interface{ Unwrap() SubscriptionProvider })
+		if !ok {
+			break
+		}
+		provider = wrapper.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// AzureCLIProvider implements SubscriptionProvider by shelling out to the Azure CLI
+type AzureCLIProvider struct {
+	// Command is the az executable, looked up in PATH
+	Command string
+}
+
+// NewAzureCLIProvider creates a provider that runs the given az executable
+func NewAzureCLIProvider(command string) *AzureCLIProvider {
+	return &AzureCLIProvider{Command: command}
+}
+
+// List implements SubscriptionProvider
+func (p *AzureCLIProvider) List() ([]Subscription, error) {
+	if !isAzureCLIAvailable(p.Command) {
+		return nil, ErrAzureCLINotFound
+	}
+
+	data, err := exec.Command(p.Command, azureAccountListArgs()...).Output()
+	if err != nil {
+		return nil, azureCLIError(err)
+	}
+
+	subscriptions, err := parseSubscriptions(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse subscription data: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// SetDefault implements SubscriptionProvider
+func (p *AzureCLIProvider) SetDefault(subscription Subscription) error {
+	if !isAzureCLIAvailable(p.Command) {
+		return ErrAzureCLINotFound
+	}
+
+	// Output rather than Run, so that stderr is kept for azureCLIError
+	if _, err := exec.Command(p.Command, "account", "set", "--subscription", subscription.ID).Output(); err != nil {
+		return azureCLIError(err)
+	}
+
+	return nil
+}
+
+// Current implements SubscriptionProvider
+func (p *AzureCLIProvider) Current() (Subscription, error) {
+	if !isAzureCLIAvailable(p.Command) {
+		return Subscription{}, ErrAzureCLINotFound
+	}
+
+	data, err := exec.Command(p.Command, azureAccountShowArgs()...).Output()
+	if err != nil {
+		return Subscription{}, azureCLIError(err)
+	}
+
+	var subscription Subscription
+	if err := json.Unmarshal(data, &subscription); err != nil {
+		return Subscription{}, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	return subscription, nil
+}
+
+func azureAccountShowArgs() []string {
+	return []string{
+		"account",
+		"show",
+		"--output",
+		"json",
+		"--query",
+		AzureShowQuery,
+	}
+}
+
+// FakeProvider is an in-memory SubscriptionProvider used for sample data and tests
+type FakeProvider struct {
+	mu            sync.Mutex
+	subscriptions []Subscription
+
+	// Cloud is the active cloud, AzureCloud if empty, and CustomClouds
+	// those registered in addition to the built-in clouds
+	Cloud        string
+	CustomClouds []Cloud
+
+	// ListErr and SetErr, when set, are returned by List and SetDefault
+	ListErr error
+	SetErr  error
+}
+
+// NewFakeProvider creates a provider serving the given subscriptions
+func NewFakeProvider(subscriptions []Subscription) *FakeProvider {
+	return &FakeProvider{subscriptions: slices.Clone(subscriptions)}
+}
+
+// NewSampleProvider creates a fake provider backed by the embedded sample data
+func NewSampleProvider() *FakeProvider {
+	subscriptions, err := parseSubscriptions(sampleData)
+	if err != nil {
+		// The sample data is embedded at build time, so this is a programming error
+		panic(fmt.Sprintf("invalid embedded sample data: %v", err))
+	}
+
+	return NewFakeProvider(subscriptions)
+}
+
+// List implements SubscriptionProvider
+func (p *FakeProvider) List() ([]Subscription, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ListErr != nil {
+		return nil, p.ListErr
+	}
+
+	return slices.Clone(p.subscriptions), nil
+}
+
+// SetDefault implements SubscriptionProvider
+func (p *FakeProvider) SetDefault(subscription Subscription) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.SetErr != nil {
+		return p.SetErr
+	}
+
+	index := slices.IndexFunc(p.subscriptions, func(s Subscription) bool {
+		return s.ID == subscription.ID
+	})
+	if index < 0 {
+		return fmt.Errorf("subscription %q not found", subscription.ID)
+	}
+
+	// Each cloud has its own default, as in the profile
+	for i := range p.subscriptions {
+		if p.subscriptions[i].CloudName != p.subscriptions[index].CloudName {
+			continue
+		}
+		p.subscriptions[i].IsDefault = i == index
+	}
+
+	return nil
+}
+
+// Current implements SubscriptionProvider
+func (p *FakeProvider) Current() (Subscription, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	index := findActiveSubscription(p.subscriptions, cmp.Or(p.Cloud, AzurePublicCloud))
+	if index < 0 {
+		return Subscription{}, ErrNoDefaultSubscription
+	}
+
+	return p.subscriptions[index], nil
+}
+
+// ActiveCloud implements CloudSwitcher
+func (p *FakeProvider) ActiveCloud() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return cmp.Or(p.Cloud, AzurePublicCloud), nil
+}
+
+// SetCloud implements CloudSwitcher
+func (p *FakeProvider) SetCloud(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Cloud = name
+	return nil
+}
+
+// Clouds implements CloudRegistry
+func (p *FakeProvider) Clouds() ([]Cloud, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	clouds := slices.Concat(builtinClouds, p.CustomClouds)
+	for i := range clouds {
+		clouds[i].IsActive = clouds[i].Name == cmp.Or(p.Cloud, AzurePublicCloud)
+	}
+	return clouds, nil
+}
+
+// RegisterCloud implements CloudRegistry
+func (p *FakeProvider) RegisterCloud(cloud Cloud) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	exists := func(c Cloud) bool { return strings.EqualFold(c.Name, cloud.Name) }
+	if slices.ContainsFunc(builtinClouds, exists) || slices.ContainsFunc(p.CustomClouds, exists) {
+		return fmt.Errorf("%w: %s", ErrCloudExists, cloud.Name)
+	}
+
+	p.CustomClouds = append(p.CustomClouds, cloud)
+	return nil
+}
+
+// isAzureCLIAvailable checks if the Azure CLI is available
+func isAzureCLIAvailable(command string) bool {
+	_, err := exec.LookPath(command)
+	return err == nil
+}
+
+func azureAccountListArgs() []string {
+	return []string{
+		"account",
+		"list",
+		"--all",
+		"--output",
+		"json",
+		"--query",
+		AzureListQuery,
+	}
+}
+
+// parseSubscriptions parses JSON data into Subscription structs
+func parseSubscriptions(data []byte) ([]Subscription, error) {
+	var subscriptions []Subscription
+	if err := json.Unmarshal(data, &subscriptions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	return subscriptions, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewSampleProvider(t *testing.T) {
+	provider := NewSampleProvider()
+
+	subs, err := provider.List()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(subs) != 5 {
+		t.Errorf("Expected 5 sample subscriptions, got %d", len(subs))
+	}
+
+	current, err := provider.Current()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if current.ID != "3f50c9e1-8f3b-4d1e-9a2b-5b8e4b4e5f1a" {
+		t.Errorf("Expected first default sample subscription, got '%s'", current.ID)
+	}
+}
+
+func TestFakeProvider_SetDefault(t *testing.T) {
+	provider := NewFakeProvider([]Subscription{
+		{ID: "sub-1", Name: "Sub 1", IsDefault: true},
+		{ID: "sub-2", Name: "Sub 2"},
+	})
+
+	if err := provider.SetDefault(Subscription{ID: "sub-2"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	current, err := provider.Current()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if current.ID != "sub-2" {
+		t.Errorf("Expected current subscription 'sub-2', got '%s'", current.ID)
+	}
+
+	subs, _ := provider.List()
+	if subs[0].IsDefault {
+		t.Error("Expected previous default to be cleared")
+	}
+
+	if err := provider.SetDefault(Subscription{ID: "missing"}); err == nil {
+		t.Error("Expected error for unknown subscription, got nil")
+	}
+}
+
+func TestFakeProvider_NoDefault(t *testing.T) {
+	provider := NewFakeProvider([]Subscription{{ID: "sub-1"}})
+
+	if _, err := provider.Current(); !errors.Is(err, ErrNoDefaultSubscription) {
+		t.Errorf("Expected ErrNoDefaultSubscription, got %v", err)
+	}
+}
+
+func TestFakeProvider_InjectedErrors(t *testing.T) {
+	provider := NewFakeProvider(nil)
+	provider.ListErr = errors.New("network timeout")
+	provider.SetErr = errors.New("permission denied")
+
+	if _, err := provider.List(); err != provider.ListErr {
+		t.Errorf("Expected injected list error, got %v", err)
+	}
+
+	if err := provider.SetDefault(Subscription{ID: "sub-1"}); err != provider.SetErr {
+		t.Errorf("Expected injected set error, got %v", err)
+	}
+}
+
+func TestApp_LoadAndChange_WithFakeProvider(t *testing.T) {
+	provider := NewFakeProvider([]Subscription{
+		{ID: "sub-1", Name: "Sub 1"},
+		{ID: "sub-2", Name: "Sub 2", IsDefault: true},
+	})
+	app := NewApp(provider)
+
+	model, _ := app.Update(app.loadSubscriptions())
+	app = model.(*App)
+
+	if app.state != StateSelectingSubscription {
+		t.Fatalf("Expected state %v, got %v", StateSelectingSubscription, app.state)
+	}
+
+	if app.selectedID != "sub-2" || app.list.Index() != 1 {
+		t.Errorf("Expected default 'sub-2' preselected, got '%s' at %d", app.selectedID, app.list.Index())
+	}
+
+	msg := app.changeSubscription(Subscription{ID: "sub-1"})()
+	model, _ = app.Update(msg)
+	app = model.(*App)
+
+	if app.state != StateShowingResult || !app.resultPage.changed {
+		t.Errorf("Expected changed result page, got state %v", app.state)
+	}
+
+	if current, _ := provider.Current(); current.ID != "sub-1" {
+		t.Errorf("Expected provider default 'sub-1', got '%s'", current.ID)
+	}
+
+	changed := app.changeSubscription(Subscription{ID: "sub-1"})().(SubscriptionChangedMsg)
+	if changed.Changed {
+		t.Error("Expected no change when selecting the active subscription")
+	}
+}
+
+func TestApp_ChangeSubscription_Error(t *testing.T) {
+	provider := NewFakeProvider([]Subscription{{ID: "sub-1", IsDefault: true}, {ID: "sub-2"}})
+	provider.SetErr = ErrSubscriptionAccess
+	app := NewApp(provider)
+	app.Update(app.loadSubscriptions())
+
+	model, _ := app.Update(app.changeSubscription(Subscription{ID: "sub-2"})())
+	app = model.(*App)
+
+	if app.state != StateError {
+		t.Errorf("Expected state %v, got %v", StateError, app.state)
+	}
+
+	if !errors.Is(app.err, provider.SetErr) {
+		t.Errorf("Expected wrapped provider error, got %v", app.err)
+	}
+}
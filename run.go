@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// Run configuration
+const (
+	CommandRun = "run"
+
+	EnvAzureSubscriptionID = "AZURE_SUBSCRIPTION_ID"
+	EnvARMSubscriptionID   = "ARM_SUBSCRIPTION_ID"
+	EnvARMTenantID         = "ARM_TENANT_ID"
+)
+
+// runRun implements 'asubselect run [--sub <query>] -- <command>'. The command
+// runs in an isolated session, so the global default is left untouched.
+func runRun(config *Config, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newCommandFlags(CommandRun, stderr)
+	query := flags.String("sub", "", "subscription ID, name or alias to run against")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("%w: %s %s [--sub <query>] -- <command> [args...]", ErrUsage, AppName, CommandRun)
+	}
+
+	path, err := exec.LookPath(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to find %s: %w", flags.Arg(0), err)
+	}
+
+	sharedDir, err := azureConfigDir()
+	if err != nil {
+		return err
+	}
+
+	session, err := NewSession(sharedDir)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	provider := config.withAliases(session.Provider())
+	chosen, err := selectSubscription(config, provider, &History{}, *query, io.Discard)
+	if err != nil || !chosen {
+		return err
+	}
+
+	subscription, err := provider.Current()
+	if err != nil {
+		return fmt.Errorf("failed to read selected subscription: %w", err)
+	}
+
+	cmd := exec.Command(path, flags.Args()[1:]...)
+	cmd.Env = append(session.Environ(), subscriptionEnviron(subscription)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+
+	return runChild(cmd)
+}
+
+// subscriptionEnviron returns the subscription variables in os/exec form
+func subscriptionEnviron(subscription Subscription) []string {
+	variables := subscriptionVariables(subscription)
+	environ := make([]string, len(variables))
+	for i, v := range variables {
+		environ[i] = v.Name + "=" + v.Value
+	}
+	return environ
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	shared := newSharedConfigDir(t)
+	t.Setenv(EnvAzureConfigDir, shared)
+
+	var stdout bytes.Buffer
+	script := `echo "$AZURE_SUBSCRIPTION_ID $ARM_SUBSCRIPTION_ID $ARM_TENANT_ID"; exit 3`
+	err := runRun(DefaultConfig(), []string{"--sub", "Sub 2", "--", "sh", "-c", script}, strings.NewReader(""), &stdout, io.Discard)
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Errorf("Expected the command's exit code 3, got %v", err)
+	}
+
+	if stdout.String() != "sub-2 sub-2 tenant-2\n" {
+		t.Errorf("Expected subscription environment, got %q", stdout.String())
+	}
+
+	if current, _ := NewNativeProvider(filepath.Join(shared, AzureProfileFileName)).Current(); current.ID != "sub-1" {
+		t.Errorf("Expected shared default to stay 'sub-1', got '%s'", current.ID)
+	}
+}
+
+func TestRunRun_Errors(t *testing.T) {
+	t.Setenv(EnvAzureConfigDir, newSharedConfigDir(t))
+
+	if err := runRun(DefaultConfig(), nil, nil, io.Discard, io.Discard); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage, got %v", err)
+	}
+
+	if err := runRun(DefaultConfig(), []string{"--", "asubselect-missing-command"}, nil, io.Discard, io.Discard); err == nil {
+		t.Error("Expected error for missing command, got nil")
+	}
+
+	if err := runRun(DefaultConfig(), []string{"--sub", "xyzzy", "--", "sh"}, nil, io.Discard, io.Discard); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("Expected ErrNoMatch, got %v", err)
+	}
+}
+
+func TestSubscriptionEnviron(t *testing.T) {
+	env := subscriptionEnviron(Subscription{ID: "sub-1", TenantID: "tenant-1"})
+	expected := []string{
+		"AZURE_SUBSCRIPTION_ID=sub-1",
+		"ARM_SUBSCRIPTION_ID=sub-1",
+		"AZURE_TENANT_ID=tenant-1",
+		"ARM_TENANT_ID=tenant-1",
+		"ASUBSELECT_USER=",
+	}
+
+	if strings.Join(env, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %v, got %v", expected, env)
+	}
+}
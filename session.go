@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// Session configuration
+const (
+	CommandShell = "shell"
+
+	// EnvSession is exported to session shells and points at the session directory
+	EnvSession = "ASUBSELECT_SESSION"
+
+	sessionDirPattern = AppName + "-session-*"
+	defaultShell      = "/bin/sh"
+)
+
+// forwardedSignals are relayed to child processes. SIGINT is deliberately
+// excluded: the terminal already delivers it to the whole foreground process
+// group, and a second copy makes tools like terraform abort ungracefully.
+var forwardedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// sessionLinkedFiles are linked into sessions from the shared directory: the
+// token and MSAL caches, so a login in one applies to all, and the installed
+// extensions
+var sessionLinkedFiles = []string{
+	"msal_token_cache.json",
+	"msal_token_cache.bin",
+	"msal_http_cache.bin",
+	"service_principal_entries.json",
+	"service_principal_entries.bin",
+	"accessTokens.json",
+	"cliextensions",
+}
+
+// sessionCopiedFiles are copied into sessions when present, so 'az config
+// set', 'az cloud set' and cloud switches only affect the session
+var sessionCopiedFiles = []string{AzureConfigFileName, AzureCloudsFileName}
+
+// Session is an isolated Azure CLI configuration directory. It links to the
+// shared token caches, but has its own copy of azureProfile.json and the
+// configuration, so switching the default or the cloud only affects processes
+// started with the session's AZURE_CONFIG_DIR.
+type Session struct {
+	Dir string
+}
+
+// NewSession creates a session directory mirroring sharedDir
+func NewSession(sharedDir string) (*Session, error) {
+	if _, err := os.Stat(sharedDir); errors.Is(err, os.ErrNotExist) {
+		return nil, ErrProfileNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", sharedDir, err)
+	}
+
+	dir, err := os.MkdirTemp("", sessionDirPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	session := &Session{Dir: dir}
+
+	for _, name := range sessionLinkedFiles {
+		shared := filepath.Join(sharedDir, name)
+		if _, err := os.Lstat(shared); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := os.Symlink(shared, filepath.Join(dir, name)); err != nil {
+			session.Close()
+			return nil, fmt.Errorf("failed to link %s: %w", name, err)
+		}
+	}
+
+	for _, name := range sessionCopiedFiles {
+		if err := copySessionFile(sharedDir, dir, name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			session.Close()
+			return nil, fmt.Errorf("failed to copy %s: %w", name, err)
+		}
+	}
+
+	if err := copySessionFile(sharedDir, dir, AzureProfileFileName); errors.Is(err, os.ErrNotExist) {
+		session.Close()
+		return nil, ErrProfileNotFound
+	} else if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to copy %s: %w", AzureProfileFileName, err)
+	}
+
+	return session, nil
+}
+
+// copySessionFile copies the named file from sharedDir into the session directory
+func copySessionFile(sharedDir, dir, name string) error {
+	data, err := os.ReadFile(filepath.Join(sharedDir, name))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name), data, 0o600)
+}
+
+// profilePath returns the session's own azureProfile.json
+func (s *Session) profilePath() string {
+	return filepath.Join(s.Dir, AzureProfileFileName)
+}
+
+// Provider returns a provider that reads and switches the session's profile
+func (s *Session) Provider() *NativeProvider {
+	return NewNativeProvider(s.profilePath())
+}
+
+// Environ returns the current environment with the session exported
+func (s *Session) Environ() []string {
+	return append(os.Environ(),
+		EnvAzureConfigDir+"="+s.Dir,
+		EnvSession+"="+s.Dir,
+	)
+}
+
+// Close removes the session directory. Linked shared files are untouched.
+func (s *Session) Close() error {
+	return os.RemoveAll(s.Dir)
+}
+
+// runShell implements 'asubselect shell [query]'. The subscription is chosen
+// as for 'set', but against the session profile, before the shell starts.
+func runShell(config *Config, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newCommandFlags(CommandShell, stderr)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("%w: %s %s [query]", ErrUsage, AppName, CommandShell)
+	}
+
+	sharedDir, err := azureConfigDir()
+	if err != nil {
+		return err
+	}
+
+	session, err := NewSession(sharedDir)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	// Session switches are private, so they are kept out of the user's history
+	chosen, err := selectSubscription(config, config.withAliases(session.Provider()), &History{}, flags.Arg(0), stdout)
+	if err != nil || !chosen {
+		return err
+	}
+
+	cmd := exec.Command(userShell())
+	cmd.Env = session.Environ()
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+
+	return runChild(cmd)
+}
+
+// userShell returns the user's preferred interactive shell
+func userShell() string {
+	for _, name := range []string{"SHELL", "COMSPEC"} {
+		if shell := os.Getenv(name); shell != "" {
+			return shell
+		}
+	}
+	return defaultShell
+}
+
+// runChild runs cmd to completion, relaying termination signals and turning
+// a non-zero exit into an ExitError carrying the child's status
+func runChild(cmd *exec.Cmd) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, append([]os.Signal{os.Interrupt}, forwardedSignals...)...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", cmd.Path, err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig != os.Interrupt {
+					_ = cmd.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: childExitCode(exitErr)}
+	}
+	return err
+}
+
+// childExitCode maps a child's exit status to ours, using the shell
+// convention of 128+n for termination by signal n
+func childExitCode(exitErr *exec.ExitError) int {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
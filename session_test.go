@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// newSharedConfigDir creates an Azure config directory with a profile and token cache
+func newSharedConfigDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeTestProfile(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, "msal_token_cache.json"), []byte("{}"), 0o600); err != nil {
+		t.Fatalf("Failed to write token cache: %v", err)
+	}
+
+	return dir
+}
+
+func TestNewSession(t *testing.T) {
+	shared := newSharedConfigDir(t)
+
+	session, err := NewSession(shared)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer session.Close()
+
+	target, err := os.Readlink(filepath.Join(session.Dir, "msal_token_cache.json"))
+	if err != nil || target != filepath.Join(shared, "msal_token_cache.json") {
+		t.Errorf("Expected token cache to be linked to the shared directory, got %q, %v", target, err)
+	}
+
+	if err := session.Provider().SetDefault(Subscription{ID: "sub-2"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if current, _ := NewNativeProvider(filepath.Join(shared, AzureProfileFileName)).Current(); current.ID != "sub-1" {
+		t.Errorf("Expected shared default to stay 'sub-1', got '%s'", current.ID)
+	}
+
+	if current, _ := session.Provider().Current(); current.ID != "sub-2" {
+		t.Errorf("Expected session default 'sub-2', got '%s'", current.ID)
+	}
+
+	if err := session.Close(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(shared, "msal_token_cache.json")); err != nil {
+		t.Errorf("Expected shared token cache to survive session cleanup, got: %v", err)
+	}
+}
+
+func TestNewSession_Config(t *testing.T) {
+	shared := newSharedConfigDir(t)
+	for name, content := range map[string]string{
+		AzureConfigFileName: "[cloud]\nname = AzureCloud\n",
+		AzureCloudsFileName: "[AzureCloud]\nsubscription = sub-1\n",
+		"commandIndex.json": "{}",
+	} {
+		if err := os.WriteFile(filepath.Join(shared, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	session, err := NewSession(shared)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer session.Close()
+
+	for _, name := range []string{AzureConfigFileName, AzureCloudsFileName} {
+		if info, err := os.Lstat(filepath.Join(session.Dir, name)); err != nil || !info.Mode().IsRegular() {
+			t.Errorf("Expected a private copy of %s, got %v", name, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(session.Dir, "commandIndex.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected only the token caches linked, got %v", err)
+	}
+
+	if err := session.Provider().SetCloud("AzureChinaCloud"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cloud, _ := readActiveCloud(filepath.Join(shared, AzureConfigFileName)); cloud != AzurePublicCloud {
+		t.Errorf("Expected the shared cloud to stay AzureCloud, got %s", cloud)
+	}
+}
+
+func TestNewSession_NotLoggedIn(t *testing.T) {
+	if _, err := NewSession(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected ErrProfileNotFound, got %v", err)
+	}
+
+	if _, err := NewSession(t.TempDir()); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Expected ErrProfileNotFound, got %v", err)
+	}
+}
+
+func TestRunShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	shared := newSharedConfigDir(t)
+	t.Setenv(EnvAzureConfigDir, shared)
+	t.Setenv("SHELL", "/bin/sh")
+
+	stdin := strings.NewReader("echo \"dir=$AZURE_CONFIG_DIR\"\nexit 4\n")
+	var stdout bytes.Buffer
+	err := runShell(DefaultConfig(), []string{"Sub 2"}, stdin, &stdout, &stdout)
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 4 {
+		t.Errorf("Expected the shell's exit code 4, got %v", err)
+	}
+
+	output := stdout.String()
+	if !strings.Contains(output, SuccessMessage) {
+		t.Errorf("Expected switch to be reported, got: %s", output)
+	}
+
+	_, dir, found := strings.Cut(strings.TrimSpace(output), "dir=")
+	if !found || dir == shared {
+		t.Fatalf("Expected the shell to get a session AZURE_CONFIG_DIR, got: %s", output)
+	}
+
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected session directory to be removed, got: %v", err)
+	}
+
+	if current, _ := NewNativeProvider(filepath.Join(shared, AzureProfileFileName)).Current(); current.ID != "sub-1" {
+		t.Errorf("Expected shared default to stay 'sub-1', got '%s'", current.ID)
+	}
+}
+
+func TestRunChild_ExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	if err := runChild(exec.Command("/bin/sh", "-c", "exit 0")); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+
+	var exitErr *ExitError
+	err := runChild(exec.Command("/bin/sh", "-c", "kill -TERM $$"))
+	if !errors.As(err, &exitErr) || exitErr.Code != 128+15 {
+		t.Errorf("Expected exit code %d for SIGTERM, got %v", 128+15, err)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Pickers: subscriptions directly, or a tenant first
+const (
+	PickerSubscription = "subscription"
+	PickerTenant       = "tenant"
+)
+
+var pickerModes = []string{PickerSubscription, PickerTenant}
+
+// Tenant-first picker configuration
+const (
+	TenantListTitle = "Select Azure Tenant"
+
+	// LastTenantFileName remembers the tenant last drilled into
+	LastTenantFileName = "last-tenant"
+)
+
+// tenantItem is a row in the tenant list
+type tenantItem struct {
+	id    string
+	name  string
+	count int
+}
+
+// Title implements list.DefaultItem interface
+func (t tenantItem) Title() string {
+	return t.name
+}
+
+// Description implements list.DefaultItem interface
+func (t tenantItem) Description() string {
+	noun := "subscriptions"
+	if t.count == 1 {
+		noun = "subscription"
+	}
+	return fmt.Sprintf("%s (%d %s)", t.id, t.count, noun)
+}
+
+// FilterValue implements list.Item interface
+func (t tenantItem) FilterValue() string {
+	return joinNonEmpty("/", t.name, t.id)
+}
+
+// defaultLastTenantPath returns the last tenant file in the state directory
+func defaultLastTenantPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, LastTenantFileName), nil
+}
+
+// loadLastTenant returns the tenant ID stored at path, if any
+func loadLastTenant(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// saveLastTenant stores the tenant ID at path
+func saveLastTenant(path, tenantID string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	return writeFileAtomic(path, []byte(tenantID+"\n"))
+}
+
+// initializeTenantList sets up the tenant list of the tenant-first picker
+func (app *App) initializeTenantList() {
+	tenantList := list.New([]list.Item{}, app.createListDelegate(), 0, 0)
+
+	app.styleList(&tenantList)
+	tenantList.Title = TenantListTitle
+	app.applyKeyMap(&tenantList)
+	tenantList.AdditionalShortHelpKeys = nil
+
+	h, v := docStyle.GetFrameSize()
+	tenantList.SetSize(width-h, height-v)
+
+	app.tenantList = tenantList
+}
+
+// tenantSubscriptions returns the subscriptions of the chosen tenant, or all
+// of them unless the tenant-first picker is in use
+func (app *App) tenantSubscriptions() []Subscription {
+	if !app.tenantFirst || app.tenant == "" {
+		return app.subscriptions
+	}
+
+	var subscriptions []Subscription
+	for _, sub := range app.subscriptions {
+		if sub.TenantID == app.tenant {
+			subscriptions = append(subscriptions, sub)
+		}
+	}
+	return subscriptions
+}
+
+// showTenants switches to the tenant list, highlighting the tenant last
+// drilled into or else the active subscription's
+func (app *App) showTenants() tea.Cmd {
+	groups := app.groupByTenant(app.subscriptions)
+	items := make([]list.Item, len(groups))
+	for i, group := range groups {
+		items[i] = tenantItem{id: group.header.tenantID, name: group.header.name, count: group.header.count}
+	}
+	cmd := app.tenantList.SetItems(items)
+
+	// Stderr belongs to the TUI, so warnings go to the status bar
+	highlight := app.tenant
+	if highlight == "" {
+		last, err := loadLastTenant(app.lastTenantPath)
+		if err != nil {
+			cmd = tea.Batch(cmd, app.tenantList.NewStatusMessage(fmt.Sprintf("Warning: %v", err)))
+		}
+		highlight = last
+	}
+	index := slices.IndexFunc(items, func(item list.Item) bool {
+		return item.(tenantItem).id == highlight
+	})
+	if index < 0 {
+		index = slices.IndexFunc(groups, func(group tenantGroup) bool {
+			return slices.ContainsFunc(group.subscriptions, func(sub Subscription) bool {
+				return sub.ID == app.selectedID
+			})
+		})
+	}
+	app.tenantList.Select(max(index, 0))
+
+	app.state = StateSelectingTenant
+	return cmd
+}
+
+// chooseTenant drills into the highlighted tenant's subscriptions
+func (app *App) chooseTenant() tea.Cmd {
+	item, ok := app.tenantList.SelectedItem().(tenantItem)
+	if !ok {
+		return nil
+	}
+
+	app.tenant = item.id
+	app.state = StateSelectingSubscription
+	app.list.ResetFilter()
+	app.list.Select(0)
+	app.updateListTitle()
+	cmd := app.setListItems(app.selectedID)
+
+	if err := saveLastTenant(app.lastTenantPath, item.id); err != nil {
+		cmd = tea.Batch(cmd, app.list.NewStatusMessage(fmt.Sprintf("Warning: %v", err)))
+	}
+	return cmd
+}
+
+// tenantName returns the display name of the chosen tenant
+func (app *App) tenantName() string {
+	for _, sub := range app.subscriptions {
+		if sub.TenantID == app.tenant {
+			return cmp.Or(sub.TenantDisplayName, sub.TenantID)
+		}
+	}
+	return app.tenant
+}
+
+// tenantListView renders the tenant selection screen
+func (app *App) tenantListView() string {
+	return lipgloss.JoinHorizontal(lipgloss.Top, "  ", app.tenantList.View())
+}
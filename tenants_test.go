@@ -0,0 +1,139 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// newTenantFirstApp returns a loaded app using the tenant-first picker,
+// remembering the last tenant at path
+func newTenantFirstApp(t *testing.T, path string) *App {
+	t.Helper()
+
+	app := NewApp(NewFakeProvider(groupTestSubscriptions))
+	app.tenantFirst = true
+	app.lastTenantPath = path
+	app.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	app.Update(app.loadSubscriptions())
+	return app
+}
+
+// tenantIDs returns the IDs of the items in the tenant list
+func tenantIDs(app *App) string {
+	var ids []string
+	for _, item := range app.tenantList.Items() {
+		ids = append(ids, item.(tenantItem).id)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestApp_TenantFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LastTenantFileName)
+	app := newTenantFirstApp(t, path)
+
+	if app.state != StateSelectingTenant {
+		t.Fatalf("Expected StateSelectingTenant, got %v", app.state)
+	}
+	if ids := tenantIDs(app); ids != "t-1,t-2" {
+		t.Errorf("Expected tenants by name, got %s", ids)
+	}
+	if item := app.tenantList.SelectedItem().(tenantItem); item.id != "t-2" || item.count != 2 {
+		t.Errorf("Expected the active subscription's tenant highlighted, got %#v", item)
+	}
+	if view := app.View(); !strings.Contains(view, TenantListTitle) || !strings.Contains(view, "Fabrikam") {
+		t.Errorf("Expected the tenant list, got:\n%s", view)
+	}
+
+	// Enter drills into the tenant's subscriptions
+	app.tenantList.Select(0)
+	app.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if app.state != StateSelectingSubscription {
+		t.Fatalf("Expected StateSelectingSubscription, got %v", app.state)
+	}
+	if labels := itemLabels(app.list.Items()); labels != "Dev,Sandbox" {
+		t.Errorf("Expected Contoso's subscriptions, got %s", labels)
+	}
+	if !strings.HasSuffix(app.list.Title, " / Contoso") {
+		t.Errorf("Expected the tenant in the title, got %q", app.list.Title)
+	}
+	if last, err := loadLastTenant(path); err != nil || last != "t-1" {
+		t.Errorf("Expected t-1 remembered, got %q, %v", last, err)
+	}
+
+	// Esc goes back to the tenants, highlighting the one left
+	_, cmd := app.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if cmd == nil {
+		t.Fatal("Expected a command going back")
+	}
+	app.Update(cmd())
+	if app.state != StateSelectingTenant {
+		t.Fatalf("Expected StateSelectingTenant after esc, got %v", app.state)
+	}
+	if item := app.tenantList.SelectedItem().(tenantItem); item.id != "t-1" {
+		t.Errorf("Expected t-1 highlighted, got %s", item.id)
+	}
+}
+
+func TestApp_TenantFirst_RemembersLastTenant(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LastTenantFileName)
+	if err := saveLastTenant(path, "t-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTenantFirstApp(t, path)
+	if item := app.tenantList.SelectedItem().(tenantItem); item.id != "t-1" {
+		t.Errorf("Expected the last tenant highlighted, got %s", item.id)
+	}
+}
+
+func TestApp_TenantFirst_SkipsSingleTenant(t *testing.T) {
+	app := NewApp(NewFakeProvider(groupTestSubscriptions[:1]))
+	app.tenantFirst = true
+	app.Update(app.loadSubscriptions())
+
+	if app.state != StateSelectingSubscription {
+		t.Errorf("Expected the subscriptions of the only tenant, got %v", app.state)
+	}
+}
+
+func TestApp_TenantFirst_InitialMatches(t *testing.T) {
+	app := NewApp(NewFakeProvider(groupTestSubscriptions))
+	app.tenantFirst = true
+	app.initialMatches = []string{"sub-1", "sub-4"}
+	app.Update(app.loadSubscriptions())
+
+	if app.state != StateSelectingSubscription {
+		t.Errorf("Expected a query to skip the tenants, got %v", app.state)
+	}
+	if len(app.list.Items()) != 2 {
+		t.Errorf("Expected the matches from both tenants, got %d", len(app.list.Items()))
+	}
+}
+
+func TestLoadLastTenant_Missing(t *testing.T) {
+	last, err := loadLastTenant(filepath.Join(t.TempDir(), LastTenantFileName))
+	if err != nil || last != "" {
+		t.Errorf("Expected no tenant, got %q, %v", last, err)
+	}
+}
+
+func TestApp_TenantFirst_Warnings(t *testing.T) {
+	// A directory can be neither read nor replaced as the last tenant file
+	app := newTenantFirstApp(t, t.TempDir())
+	if view := app.View(); !strings.Contains(view, "Warning: failed to read") {
+		t.Errorf("Expected the read error in the status bar, got:\n%s", view)
+	}
+
+	// Room for the status message after the tenant in the title
+	app.Update(tea.WindowSizeMsg{Width: 160, Height: 40})
+	app.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if app.state != StateSelectingSubscription {
+		t.Fatalf("Expected the tenant chosen despite the error, got %v", app.state)
+	}
+	if view := app.View(); !strings.Contains(view, "Warning:") {
+		t.Errorf("Expected the write error in the status bar, got:\n%s", view)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DefaultThemeName is the flavor custom themes start from, and the theme
+// used when the background cannot be told
+const DefaultThemeName = FlavorMocha
+
+// ThemeAuto picks a light or dark flavor to match the terminal background
+const ThemeAuto = "auto"
+
+// Terminal background settings. BackgroundAuto queries the terminal.
+const (
+	BackgroundAuto  = "auto"
+	BackgroundLight = "light"
+	BackgroundDark  = "dark"
+)
+
+// Flavors used by ThemeAuto
+const (
+	LightThemeName = FlavorLatte
+	DarkThemeName  = FlavorMocha
+)
+
+var backgroundNames = []string{BackgroundAuto, BackgroundLight, BackgroundDark}
+
+// hexColorPattern matches the colors accepted in custom themes
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Theme maps the semantic roles used by the UI to the colors of a palette
+type Theme struct {
+	Name    string
+	Flavor  string
+	Palette Palette
+
+	ActiveBorder    lipgloss.Color
+	InactiveBorder  lipgloss.Color
+	Success         lipgloss.Color
+	Error           lipgloss.Color
+	Info            lipgloss.Color
+	Text            lipgloss.Color
+	Subtext         lipgloss.Color
+	Muted           lipgloss.Color
+	Accent          lipgloss.Color
+	Header          lipgloss.Color
+	TitleBackground lipgloss.Color
+
+	// Overrides from the configuration, kept for 'config show'. Palette
+	// overrides are hex colors, role overrides palette entries or hex colors.
+	paletteOverrides map[string]string
+	roleOverrides    map[string]string
+}
+
+// defaultRoles maps each role to the palette entry it uses by default
+var defaultRoles = map[string]string{
+	"active_border":    "lavender",
+	"inactive_border":  "overlay0",
+	"success":          "green",
+	"error":            "red",
+	"info":             "teal",
+	"text":             "text",
+	"subtext":          "subtext1",
+	"muted":            "overlay1",
+	"accent":           "rosewater",
+	"header":           "mauve",
+	"title_background": "mantle",
+}
+
+// flavorRoles replaces default roles per flavor. On Latte the pastel accents
+// are too faint against the light background.
+var flavorRoles = map[string]map[string]string{
+	FlavorLatte: {
+		"accent":  "mauve",
+		"success": "teal",
+		"info":    "blue",
+		"muted":   "overlay2",
+	},
+}
+
+// defaultRole returns the palette entry a role uses by default in flavor
+func defaultRole(flavor, role string) string {
+	if entry, ok := flavorRoles[flavor][role]; ok {
+		return entry
+	}
+	return defaultRoles[role]
+}
+
+// roles maps role names, as used in the configuration, to the theme's colors
+func (t *Theme) roles() map[string]*lipgloss.Color {
+	return map[string]*lipgloss.Color{
+		"active_border":    &t.ActiveBorder,
+		"inactive_border":  &t.InactiveBorder,
+		"success":          &t.Success,
+		"error":            &t.Error,
+		"info":             &t.Info,
+		"text":             &t.Text,
+		"subtext":          &t.Subtext,
+		"muted":            &t.Muted,
+		"accent":           &t.Accent,
+		"header":           &t.Header,
+		"title_background": &t.TitleBackground,
+	}
+}
+
+// NewTheme returns a theme using palette with the default role mapping
+func NewTheme(name string, palette Palette) Theme {
+	theme := Theme{Name: name, Flavor: name, Palette: palette}
+	// Default roles always name palette entries, so this cannot fail
+	_ = theme.resolve()
+	return theme
+}
+
+// DefaultTheme returns the default Catppuccin Mocha theme
+func DefaultTheme() Theme {
+	return NewTheme(DefaultThemeName, MochaPalette)
+}
+
+// resolve applies the palette and role overrides on top of the flavor
+func (t *Theme) resolve() error {
+	colors := t.Palette.colors()
+	for _, entry := range slices.Sorted(maps.Keys(t.paletteOverrides)) {
+		color, ok := colors[entry]
+		if !ok {
+			return fmt.Errorf("unknown palette entry %q", entry)
+		}
+		value := t.paletteOverrides[entry]
+		if !hexColorPattern.MatchString(value) {
+			return fmt.Errorf("palette.%s must be a hex color like \"#1e1e2e\", got %q", entry, value)
+		}
+		*color = lipgloss.Color(strings.ToLower(value))
+	}
+
+	roles := t.roles()
+	for _, role := range slices.Sorted(maps.Keys(t.roleOverrides)) {
+		if _, ok := roles[role]; !ok {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+	for role, color := range roles {
+		value := cmp.Or(t.roleOverrides[role], defaultRole(t.Flavor, role))
+		if hexColorPattern.MatchString(value) {
+			*color = lipgloss.Color(strings.ToLower(value))
+			continue
+		}
+		entry, ok := colors[value]
+		if !ok {
+			return fmt.Errorf("roles.%s must be a palette entry or a hex color, got %q", role, value)
+		}
+		*color = *entry
+	}
+
+	return nil
+}
+
+// normalizeThemeName lower-cases a theme name, accepting "frappé" for "frappe"
+func normalizeThemeName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "é", "e")
+}
+
+// themeNames returns auto and the built-in flavors followed by the custom themes
+func (c *Config) themeNames() []string {
+	return slices.Concat([]string{ThemeAuto}, flavorNames, slices.Sorted(maps.Keys(c.Themes)))
+}
+
+// setTheme selects a built-in flavor or a custom theme by name
+func (c *Config) setTheme(value string) error {
+	name := normalizeThemeName(value)
+	if _, ok := flavors[name]; !ok && name != ThemeAuto {
+		if _, ok := c.Themes[name]; !ok {
+			return fmt.Errorf("must be one of %s", strings.Join(c.themeNames(), ", "))
+		}
+	}
+	c.Theme = name
+	return nil
+}
+
+// ActiveTheme returns the configured theme. With ThemeAuto this may query the
+// terminal for its background color.
+func (c *Config) ActiveTheme() Theme {
+	name := c.Theme
+	if name == ThemeAuto {
+		name = LightThemeName
+		if c.hasDarkBackground() {
+			name = DarkThemeName
+		}
+	}
+
+	if theme, ok := c.Themes[name]; ok {
+		return theme
+	}
+	if palette, ok := flavors[name]; ok {
+		return NewTheme(name, palette)
+	}
+	return DefaultTheme()
+}
+
+// hasDarkBackground reports whether the background of the terminal the TUI is
+// drawn on is dark, unless overridden by the background setting
+func (c *Config) hasDarkBackground() bool {
+	switch c.Background {
+	case BackgroundLight:
+		return false
+	case BackgroundDark:
+		return true
+	default:
+		return lipgloss.DefaultRenderer().HasDarkBackground()
+	}
+}
+
+// decodeThemes decodes the [themes.<name>] tables. Each theme starts from a
+// flavor and may override palette entries and the colors used for roles.
+func (c *Config) decodeThemes(value any) error {
+	tables, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%w: themes must be a table", ErrInvalidConfig)
+	}
+
+	c.Themes = make(map[string]Theme, len(tables))
+	for _, key := range slices.Sorted(maps.Keys(tables)) {
+		name := normalizeThemeName(key)
+		if _, builtin := flavors[name]; builtin {
+			return fmt.Errorf("%w: themes.%s would replace a built-in flavor", ErrInvalidConfig, key)
+		}
+		if _, taken := c.Themes[name]; taken || name == "" {
+			return fmt.Errorf("%w: theme name %q is invalid or used more than once", ErrInvalidConfig, key)
+		}
+
+		theme, err := decodeTheme(name, tables[key])
+		if err != nil {
+			return fmt.Errorf("%w: themes.%s: %v", ErrInvalidConfig, key, err)
+		}
+		c.Themes[name] = theme
+	}
+
+	return nil
+}
+
+// decodeTheme decodes a single custom theme
+func decodeTheme(name string, value any) (Theme, error) {
+	table, ok := value.(map[string]any)
+	if !ok {
+		return Theme{}, errors.New("must be a table")
+	}
+
+	theme := Theme{Name: name, Flavor: DefaultThemeName}
+	for _, key := range slices.Sorted(maps.Keys(table)) {
+		var err error
+		switch key {
+		case "flavor":
+			flavor, ok := table[key].(string)
+			theme.Flavor = normalizeThemeName(flavor)
+			if _, known := flavors[theme.Flavor]; !ok || !known {
+				return Theme{}, fmt.Errorf("flavor must be one of %s", strings.Join(flavorNames, ", "))
+			}
+		case "palette":
+			theme.paletteOverrides, err = decodeColorTable(key, table[key])
+		case "roles":
+			theme.roleOverrides, err = decodeColorTable(key, table[key])
+		default:
+			err = fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return Theme{}, err
+		}
+	}
+
+	theme.Palette = flavors[theme.Flavor]
+	if err := theme.resolve(); err != nil {
+		return Theme{}, err
+	}
+	return theme, nil
+}
+
+// decodeColorTable decodes a table of string values, lower-casing its keys
+func decodeColorTable(name string, value any) (map[string]string, error) {
+	table, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a table", name)
+	}
+
+	colors := make(map[string]string, len(table))
+	for key, value := range table {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s must be a string", name, key)
+		}
+		colors[strings.ToLower(key)] = strings.ToLower(strings.TrimSpace(s))
+	}
+	return colors, nil
+}
+
+// writeThemes writes the custom themes as [themes.<name>] tables
+func writeThemes(out io.Writer, themes map[string]Theme) {
+	for _, name := range slices.Sorted(maps.Keys(themes)) {
+		theme := themes[name]
+		fmt.Fprintf(out, "\n[themes.%s]\nflavor = %s\n", tomlString(name), tomlString(theme.Flavor))
+		writeColorTable(out, name, "palette", theme.paletteOverrides)
+		writeColorTable(out, name, "roles", theme.roleOverrides)
+	}
+}
+
+// writeColorTable writes a theme's palette or roles table, if not empty
+func writeColorTable(out io.Writer, theme, table string, colors map[string]string) {
+	if len(colors) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "\n[themes.%s.%s]\n", tomlString(theme), table)
+	for _, key := range slices.Sorted(maps.Keys(colors)) {
+		fmt.Fprintf(out, "%s = %s\n", key, tomlString(colors[key]))
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestFlavors_Palettes(t *testing.T) {
+	if len(flavors) != len(flavorNames) {
+		t.Fatalf("Expected a palette for each of %v", flavorNames)
+	}
+
+	for _, name := range flavorNames {
+		palette := flavors[name]
+		for entry, color := range palette.colors() {
+			if !hexColorPattern.MatchString(string(*color)) {
+				t.Errorf("Expected %s.%s to be a hex color, got %q", name, entry, *color)
+			}
+		}
+	}
+
+	if MochaPalette.Base != Base || LattePalette.Base != "#eff1f5" {
+		t.Error("Expected Mocha to use the palette constants and Latte its own colors")
+	}
+}
+
+func TestNewTheme_DefaultRoles(t *testing.T) {
+	theme := NewTheme(FlavorFrappe, FrappePalette)
+
+	expected := map[string]lipgloss.Color{
+		"ActiveBorder":   FrappePalette.Lavender,
+		"InactiveBorder": FrappePalette.Overlay0,
+		"Success":        FrappePalette.Green,
+		"Error":          FrappePalette.Red,
+		"Info":           FrappePalette.Teal,
+		"Text":           FrappePalette.Text,
+		"Accent":         FrappePalette.Rosewater,
+	}
+	actual := map[string]lipgloss.Color{
+		"ActiveBorder":   theme.ActiveBorder,
+		"InactiveBorder": theme.InactiveBorder,
+		"Success":        theme.Success,
+		"Error":          theme.Error,
+		"Info":           theme.Info,
+		"Text":           theme.Text,
+		"Accent":         theme.Accent,
+	}
+	for role, color := range expected {
+		if actual[role] != color {
+			t.Errorf("Expected %s to be %s, got %s", role, color, actual[role])
+		}
+	}
+
+	// Latte swaps the faintest accents for darker palette entries
+	latte := NewTheme(FlavorLatte, LattePalette)
+	if latte.Accent != LattePalette.Mauve || latte.Success != LattePalette.Teal || latte.Text != LattePalette.Text {
+		t.Errorf("Expected Latte's role adjustments, got %+v", latte)
+	}
+
+	// The default theme matches the semantic constants
+	mocha := DefaultTheme()
+	if mocha.ActiveBorder != ActiveBorder || mocha.InactiveBorder != InactiveBorder ||
+		mocha.Success != Success || mocha.Error != Error || mocha.Info != Info {
+		t.Errorf("Expected the default theme to match the semantic constants, got %+v", mocha)
+	}
+}
+
+func TestLoadConfig_Themes(t *testing.T) {
+	path := writeTestConfig(t, `
+theme = "work"
+
+[themes.work]
+flavor = "Frappé"
+
+[themes.work.palette]
+lavender = "#ABCDEF"
+
+[themes.work.roles]
+accent = "lavender"
+error = "#ff0000"
+`)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	theme := config.ActiveTheme()
+	if theme.Name != "work" || theme.Flavor != FlavorFrappe {
+		t.Errorf("Expected the custom theme based on Frappé, got %s/%s", theme.Name, theme.Flavor)
+	}
+	if theme.ActiveBorder != "#abcdef" || theme.Accent != "#abcdef" {
+		t.Errorf("Expected the palette override to apply to roles, got %s and %s", theme.ActiveBorder, theme.Accent)
+	}
+	if theme.Error != "#ff0000" || theme.Success != FrappePalette.Green {
+		t.Errorf("Expected role overrides on top of the flavor, got %s and %s", theme.Error, theme.Success)
+	}
+
+	// Round trip through 'config show'
+	var out bytes.Buffer
+	if err := runConfig(config, []string{"show"}, nil, &out, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	shown := DefaultConfig()
+	if err := shown.decode(out.String()); err != nil {
+		t.Fatalf("Expected shown configuration to be valid, got: %v\n%s", err, out.String())
+	}
+	if shownTheme := shown.ActiveTheme(); !reflect.DeepEqual(shownTheme, theme) {
+		t.Errorf("Expected the theme to round-trip, got %+v", shownTheme)
+	}
+}
+
+func TestConfig_ThemeSetting(t *testing.T) {
+	config, err := loadUserConfig(writeTestConfig(t, ""), map[string]string{"theme": "Latte"}, io.Discard)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if theme := config.ActiveTheme(); theme.Name != FlavorLatte || theme.Text != LattePalette.Text {
+		t.Errorf("Expected the Latte theme, got %+v", theme)
+	}
+
+	if _, err := loadUserConfig(writeTestConfig(t, ""), map[string]string{"theme": "solarized"}, io.Discard); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage for an unknown theme, got %v", err)
+	}
+
+	if config := DefaultConfig(); config.Theme != ThemeAuto || config.Background != BackgroundAuto {
+		t.Errorf("Expected the theme to follow the background by default, got %s/%s", config.Theme, config.Background)
+	}
+}
+
+func TestConfig_AutoTheme(t *testing.T) {
+	config := DefaultConfig()
+
+	config.Background = BackgroundLight
+	if theme := config.ActiveTheme(); theme.Name != LightThemeName {
+		t.Errorf("Expected %s on a light background, got %s", LightThemeName, theme.Name)
+	}
+	config.Background = BackgroundDark
+	if theme := config.ActiveTheme(); theme.Name != DarkThemeName {
+		t.Errorf("Expected %s on a dark background, got %s", DarkThemeName, theme.Name)
+	}
+
+	// Detected from the terminal
+	defer lipgloss.SetHasDarkBackground(lipgloss.HasDarkBackground())
+	config.Background = BackgroundAuto
+	lipgloss.SetHasDarkBackground(false)
+	if theme := config.ActiveTheme(); theme.Name != LightThemeName {
+		t.Errorf("Expected %s when a light background is detected, got %s", LightThemeName, theme.Name)
+	}
+	lipgloss.SetHasDarkBackground(true)
+	if theme := config.ActiveTheme(); theme.Name != DarkThemeName {
+		t.Errorf("Expected %s when a dark background is detected, got %s", DarkThemeName, theme.Name)
+	}
+
+	// An explicit theme ignores the background
+	config.Theme = FlavorFrappe
+	config.Background = BackgroundLight
+	if theme := config.ActiveTheme(); theme.Name != FlavorFrappe {
+		t.Errorf("Expected the configured theme, got %s", theme.Name)
+	}
+}
+
+func TestConfig_BackgroundSetting(t *testing.T) {
+	t.Setenv("ASUBSELECT_BACKGROUND", "Light")
+	config, err := loadUserConfig(writeTestConfig(t, ""), nil, io.Discard)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if config.Background != BackgroundLight || config.Origin("background") != OriginEnv {
+		t.Errorf("Expected the background from the environment, got %s (%s)", config.Background, config.Origin("background"))
+	}
+
+	if _, err := loadUserConfig(writeTestConfig(t, ""), map[string]string{"background": "grey"}, io.Discard); !errors.Is(err, ErrUsage) {
+		t.Errorf("Expected ErrUsage for an unknown background, got %v", err)
+	}
+}
+
+func TestLoadConfig_ThemeErrors(t *testing.T) {
+	tests := map[string]string{
+		"themes = 1":                                    "themes must be a table",
+		"[themes.mocha]":                                "would replace a built-in flavor",
+		"[themes.x]\nflavor = \"dracula\"":              "flavor must be one of latte, frappe, macchiato, mocha",
+		"[themes.x]\ncolor = \"red\"":                   "unknown key",
+		"[themes.x.palette]\nneon = \"#ffffff\"":        "unknown palette entry",
+		"[themes.x.palette]\nbase = \"white\"":          "palette.base must be a hex color",
+		"[themes.x.roles]\nglow = \"red\"":              "unknown role",
+		"[themes.x.roles]\naccent = \"cyan\"":           "roles.accent must be a palette entry or a hex color",
+		"[themes.x.roles]\naccent = 1":                  "roles.accent must be a string",
+		"theme = \"x\"":                                 "theme must be one of auto, latte, frappe, macchiato, mocha",
+		"theme = \"y\"\n[themes.x]\nflavor = \"latte\"": "must be one of auto, latte, frappe, macchiato, mocha, x",
+	}
+
+	for content, expected := range tests {
+		_, err := LoadConfig(writeTestConfig(t, content))
+		if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error containing %q for %q, got %v", expected, content, err)
+		}
+	}
+}
+
+func TestNewUserApp_Theme(t *testing.T) {
+	config := DefaultConfig()
+	if err := config.setTheme(FlavorLatte); err != nil {
+		t.Fatal(err)
+	}
+
+	app := newUserApp(config, NewSampleProvider())
+	if app.theme.Name != FlavorLatte {
+		t.Fatalf("Expected the Latte theme, got %s", app.theme.Name)
+	}
+
+	delegate := app.createListDelegate()
+	if !reflect.DeepEqual(delegate.theme, app.theme) {
+		t.Error("Expected the list delegate to use the active theme")
+	}
+	if fg := delegate.Styles.SelectedTitle.GetForeground(); fg != LattePalette.Mauve {
+		t.Errorf("Expected selected items in the Latte accent, got %v", fg)
+	}
+	if bg := app.list.Styles.Title.GetBackground(); bg != LattePalette.Mantle {
+		t.Errorf("Expected the title on the Latte mantle, got %v", bg)
+	}
+	if rp := NewResultPage(true, ResultTimeout, app.theme); rp.theme.Success != LattePalette.Teal {
+		t.Errorf("Expected the result page to use the Latte success color, got %s", rp.theme.Success)
+	}
+}